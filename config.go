@@ -1,17 +1,42 @@
 package main
 
 import (
+	_ "embed"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 )
 
 type config struct {
-	port string
+	port            string
+	maintenanceMode bool
+	configFile      string
+	shutdownTimeout time.Duration
+	csp             string
+	forceHTTPS      bool
+	rateLimitRPS    float64
+	rateLimitBurst  int
+	corsOrigins     string
+	tlsCert         string
+	tlsKey          string
+	maxBodyBytes    int64
+	envPrefix       string
+	basePath        string
+	httpRedirect    string
+	baseURL         string
 }
 
+//go:embed csp.txt
+var cspTxt string
+
+// defaultCSP allows the inline styles and the embedded videos, maps, and forms.
+// The site generator also writes it into the header files of other hosts.
+var defaultCSP = strings.TrimSpace(cspTxt)
+
 func (cfg *config) parseArgsAndEnv(out io.Writer, args ...string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("first argument must be program name")
@@ -19,25 +44,74 @@ func (cfg *config) parseArgsAndEnv(out io.Writer, args ...string) error {
 	programName, programArgs := args[0], args[1:]
 	fs := flag.NewFlagSet(programName, flag.ExitOnError)
 	fs.StringVar(&cfg.port, "port", "8000", "the port to run the site on")
+	fs.BoolVar(&cfg.maintenanceMode, "maintenance-mode", false, "respond to all requests with the maintenance page")
+	fs.StringVar(&cfg.configFile, "config", "", "the path of a json file of flag names and values, which flags and environment variables override")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 15*time.Second, "the maximum time to wait for active requests to finish when the server is stopped")
+	fs.StringVar(&cfg.csp, "csp", defaultCSP, "the Content-Security-Policy header of responses")
+	fs.BoolVar(&cfg.forceHTTPS, "force-https", false, "redirect requests that a proxy forwarded over http to https")
+	fs.Float64Var(&cfg.rateLimitRPS, "rate-limit-rps", 0, "the requests per second allowed from each ip address, 0 allows any rate")
+	fs.IntVar(&cfg.rateLimitBurst, "rate-limit-burst", 20, "the requests allowed from an ip address at once before it is rate limited")
+	fs.StringVar(&cfg.corsOrigins, "cors-origins", "", "a comma-separated list of origins that can make cross-origin requests, * allows all origins")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "", "the certificate file to serve https with, which is reloaded when it changes")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "", "the private key file of the tls certificate")
+	fs.Int64Var(&cfg.maxBodyBytes, "max-body-bytes", 1<<20, "the largest body of a POST or PUT request that is read")
+	fs.StringVar(&cfg.basePath, "base-path", "/", "the path the site is served under, such as /enlighten/")
+	fs.StringVar(&cfg.baseURL, "base-url", "https://enlightenkitsap.org", "the url the site is hosted at, which the canonical Link headers of pages are to, empty to not set them")
+	fs.StringVar(&cfg.httpRedirect, "http-redirect-addr", ":80", "the address that redirects http requests to https when tls is enabled, empty to not redirect")
+	fs.StringVar(&cfg.envPrefix, "env-prefix", "", "the prefix of the environment variables of the flags, such as ENLIGHTEN_ to read the port from ENLIGHTEN_PORT")
 	if err := fs.Parse(programArgs); err != nil {
 		return fmt.Errorf("parsing program args: %w", err)
 	}
-	if err := cfg.parseEnvVars(fs); err != nil {
+	if err := cfg.parseEnvVars(fs, cfg.envPrefix); err != nil {
 		return fmt.Errorf("setting value from environment variable: %w", err)
 	}
+	if len(cfg.configFile) != 0 {
+		if err := cfg.parseConfigFile(fs); err != nil {
+			return fmt.Errorf("setting value from config file: %w", err)
+		}
+	}
 	return nil
 }
 
-func (cfg *config) parseEnvVars(fs *flag.FlagSet) error {
+// tlsEnabled is true when both the certificate and key files are set.
+func (cfg config) tlsEnabled() bool {
+	return len(cfg.tlsCert) != 0 && len(cfg.tlsKey) != 0
+}
+
+// httpsPort is the port that https requests are served on.
+// It is empty if a proxy serves https on the default port.
+func (cfg config) httpsPort() string {
+	if !cfg.tlsEnabled() {
+		return ""
+	}
+	return cfg.port
+}
+
+// corsOriginList splits the comma-separated cors origins.
+func (cfg config) corsOriginList() []string {
+	var origins []string
+	for _, o := range strings.Split(cfg.corsOrigins, ",") {
+		if o = strings.TrimSpace(o); len(o) != 0 {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// parseEnvVars sets the flags from environment variables named by the prefix and the upper case flag name, such as PORT for -port.
+func (cfg *config) parseEnvVars(fs *flag.FlagSet, prefix string) error {
 	var lastErr error
 	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "env-prefix" {
+			return // only set by the program args
+		}
 		upperName := strings.ToUpper(f.Name)
-		name := strings.ReplaceAll(upperName, "-", "_")
+		name := prefix + strings.ReplaceAll(upperName, "-", "_")
 		val, ok := os.LookupEnv(name)
 		if !ok {
 			return
 		}
-		if err := f.Value.Set(val); err != nil {
+		if err := fs.Set(f.Name, val); err != nil {
 			lastErr = err
 		}
 	})
@@ -46,3 +120,36 @@ func (cfg *config) parseEnvVars(fs *flag.FlagSet) error {
 	}
 	return nil
 }
+
+// parseConfigFile sets the flags that were not set by args or environment variables from the values in the config file.
+func (cfg *config) parseConfigFile(fs *flag.FlagSet) error {
+	data, err := os.ReadFile(cfg.configFile)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, raw := range values {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag in config file: %q", name)
+		}
+		if set[name] {
+			continue
+		}
+		val := string(raw)
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			val = s
+		}
+		if err := fs.Set(name, val); err != nil {
+			return fmt.Errorf("setting %v: %w", name, err)
+		}
+	}
+	return nil
+}
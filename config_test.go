@@ -2,10 +2,26 @@ package main
 
 import (
 	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseArgsAndEnv(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"port": "7", "maintenance-mode": true}`), 0600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+	badConfigFile := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(badConfigFile, []byte(`{"color": "red"}`), 0600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
 	tests := []struct {
 		name   string
 		args   []string
@@ -17,17 +33,50 @@ func TestParseArgsAndEnv(t *testing.T) {
 			name:   "defaults",
 			wantOk: true,
 			want: config{
-				port: "8000",
+				port:            "8000",
+				shutdownTimeout: 15 * time.Second,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
 			},
 		},
 		{
 			name: "all args",
 			args: []string{
 				"-port=1",
+				"-maintenance-mode",
+				"-shutdown-timeout=1s",
+				"-csp=default-src 'none'",
+				"-force-https",
+				"-rate-limit-rps=2.5",
+				"-rate-limit-burst=5",
+				"-cors-origins=*",
+				"-tls-cert=cert.pem",
+				"-tls-key=key.pem",
+				"-max-body-bytes=100",
+				"-base-path=/enlighten/",
+				"-http-redirect-addr=:8080",
+				"-base-url=https://example.org",
 			},
 			wantOk: true,
 			want: config{
-				port: "1",
+				port:            "1",
+				maintenanceMode: true,
+				shutdownTimeout: time.Second,
+				csp:             "default-src 'none'",
+				forceHTTPS:      true,
+				rateLimitRPS:    2.5,
+				rateLimitBurst:  5,
+				corsOrigins:     "*",
+				tlsCert:         "cert.pem",
+				tlsKey:          "key.pem",
+				maxBodyBytes:    100,
+				basePath:        "/enlighten/",
+				httpRedirect:    ":8080",
+				baseURL:         "https://example.org",
 			},
 		},
 		{
@@ -37,13 +86,128 @@ func TestParseArgsAndEnv(t *testing.T) {
 			},
 			env: [][]string{
 				{"PORT", "11"},
+				{"MAINTENANCE_MODE", "true"},
+				{"SHUTDOWN_TIMEOUT", "1m"},
+			},
+			wantOk: true,
+			want: config{
+				port:            "11",
+				maintenanceMode: true,
+				shutdownTimeout: time.Minute,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
+			},
+		},
+		{
+			name: "env prefix",
+			args: []string{"-env-prefix=ENLIGHTEN_"},
+			env: [][]string{
+				{"PORT", "1111"}, // ignored
+				{"ENLIGHTEN_PORT", "9999"},
+				{"ENV_PREFIX", "OTHER_"}, // ignored
+			},
+			wantOk: true,
+			want: config{
+				port:            "9999",
+				shutdownTimeout: 15 * time.Second,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
+				envPrefix:       "ENLIGHTEN_",
+			},
+		},
+		{
+			name:   "config file",
+			args:   []string{"-config=" + configFile},
+			wantOk: true,
+			want: config{
+				port:            "7",
+				maintenanceMode: true,
+				configFile:      configFile,
+				shutdownTimeout: 15 * time.Second,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
+			},
+		},
+		{
+			name:   "args override config file",
+			args:   []string{"-config=" + configFile, "-port=8"},
+			wantOk: true,
+			want: config{
+				port:            "8",
+				maintenanceMode: true,
+				configFile:      configFile,
+				shutdownTimeout: 15 * time.Second,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
+			},
+		},
+		{
+			name: "env overrides config file",
+			args: []string{"-config=" + configFile},
+			env: [][]string{
+				{"MAINTENANCE_MODE", "false"},
 			},
 			wantOk: true,
 			want: config{
-				port: "11",
+				port:            "7",
+				configFile:      configFile,
+				shutdownTimeout: 15 * time.Second,
+				csp:             defaultCSP,
+				rateLimitBurst:  20,
+				maxBodyBytes:    1 << 20,
+				basePath:        "/",
+				httpRedirect:    ":80",
+				baseURL:         "https://enlightenkitsap.org",
 			},
 		},
+		{
+			name: "missing config file",
+			args: []string{"-config=" + filepath.Join(t.TempDir(), "missing.json")},
+		},
+		{
+			name: "unknown flag in config file",
+			args: []string{"-config=" + badConfigFile},
+		},
 	}
+	t.Run("cors origins", func(t *testing.T) {
+		cfg := config{corsOrigins: "https://a.example, https://b.example,,"}
+		want := []string{"https://a.example", "https://b.example"}
+		if got := cfg.corsOriginList(); !slices.Equal(want, got) {
+			t.Errorf("not equal: wanted %q, got %q", want, got)
+		}
+	})
+	t.Run("tls enabled", func(t *testing.T) {
+		tests := []struct {
+			cfg  config
+			want bool
+		}{
+			{config{}, false},
+			{config{tlsCert: "cert.pem"}, false},
+			{config{tlsKey: "key.pem"}, false},
+			{config{tlsCert: "cert.pem", tlsKey: "key.pem"}, true},
+		}
+		for _, test := range tests {
+			if got := test.cfg.tlsEnabled(); test.want != got {
+				t.Errorf("%+v: wanted %v, got %v", test.cfg, test.want, got)
+			}
+		}
+	})
 	t.Run("no program name", func(t *testing.T) {
 		cfg := new(config)
 		if err := cfg.parseArgsAndEnv(io.Discard); err == nil {
@@ -74,3 +238,80 @@ func TestParseArgsAndEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultCSPAllowsSite(t *testing.T) {
+	policy := make(map[string][]string)
+	for _, d := range strings.Split(defaultCSP, ";") {
+		fields := strings.Fields(d)
+		if len(fields) != 0 {
+			policy[fields[0]] = fields[1:]
+		}
+	}
+	sources := func(directive string) []string {
+		if s, ok := policy[directive]; ok {
+			return s
+		}
+		return policy["default-src"]
+	}
+	allowed := func(directive, u string) bool {
+		s := sources(directive)
+		switch {
+		case strings.HasPrefix(u, "data:"):
+			return slices.Contains(s, "data:")
+		case strings.HasPrefix(u, "http://"), strings.HasPrefix(u, "https://"):
+			parts := strings.SplitN(u, "/", 4)
+			return slices.Contains(s, strings.Join(parts[:3], "/"))
+		default:
+			return slices.Contains(s, "'self'")
+		}
+	}
+	tagRE := regexp.MustCompile(`<([a-zA-Z]+)(\s[^>]*)?>`)
+	attrRE := regexp.MustCompile(`\s([a-zA-Z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	srcDirectives := map[string]string{
+		"script": "script-src",
+		"img":    "img-src",
+		"source": "media-src",
+		"audio":  "media-src",
+		"video":  "media-src",
+		"iframe": "frame-src",
+	}
+	err := fs.WalkDir(_siteFS, "build/site", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".html" {
+			return err
+		}
+		data, err := _siteFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tagRE.FindAllStringSubmatch(string(data), -1) {
+			name := strings.ToLower(tag[1])
+			attrs := make(map[string]string)
+			for _, a := range attrRE.FindAllStringSubmatch(tag[2], -1) {
+				attrs[strings.ToLower(a[1])] = strings.Trim(a[2], `"'`)
+			}
+			for k, v := range attrs {
+				switch {
+				case strings.HasPrefix(k, "on") && !slices.Contains(sources("script-src"), "'unsafe-inline'"):
+					t.Errorf("%v: inline event handler blocked by the policy: %v", p, tag[0])
+				case k == "style" && !slices.Contains(sources("style-src"), "'unsafe-inline'"):
+					t.Errorf("%v: inline style blocked by the policy: %v", p, tag[0])
+				case strings.HasPrefix(strings.TrimSpace(strings.ToLower(v)), "javascript:"):
+					t.Errorf("%v: javascript url blocked by the policy: %v", p, tag[0])
+				}
+			}
+			src, hasSrc := attrs["src"]
+			switch {
+			case name == "script" && !hasSrc && attrs["type"] != "application/ld+json" && !slices.Contains(sources("script-src"), "'unsafe-inline'"):
+				t.Errorf("%v: inline script blocked by the policy", p)
+			case hasSrc && len(srcDirectives[name]) != 0 && !allowed(srcDirectives[name], src):
+				t.Errorf("%v: %v blocked by the policy: %v", p, srcDirectives[name], tag[0])
+			case name == "link" && attrs["rel"] == "stylesheet" && !allowed("style-src", attrs["href"]):
+				t.Errorf("%v: style-src blocked by the policy: %v", p, tag[0])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading site: %v", err)
+	}
+}
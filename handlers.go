@@ -1,50 +1,436 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
 	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"enlightenkitsap.org/internal/headers"
 )
 
-func withProxy(h http.Handler, src, dest string) http.HandlerFunc {
+func withProxy(h http.Handler, basePath, src, dest string) http.HandlerFunc {
+	return withProxyMap(h, basePath, map[string]string{src: dest})
+}
+
+// withProxyMap serves the destination path for requests to each source path.
+// The base path the site is served under is removed from requests first.
+// Paths are rewritten at most once: a destination that is also a source is not rewritten again, so cycles are harmless.
+func withProxyMap(h http.Handler, basePath string, rewrites map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := stripBasePath(r.URL.Path, basePath)
+		if dest, ok := rewrites[p]; ok {
+			p = dest
+		}
+		r.URL.Path = p
+		h.ServeHTTP(w, r)
+	}
+}
+
+// addBasePath adds the base path, such as /enlighten/, to the start of each url path.
+func addBasePath(urlPaths []string, basePath string) []string {
+	prefix := strings.TrimSuffix(basePath, "/")
+	prefixed := make([]string, len(urlPaths))
+	for i, p := range urlPaths {
+		prefixed[i] = prefix + p
+	}
+	return prefixed
+}
+
+// stripBasePath removes the base path, such as /enlighten/, from the start of the url path.
+// Paths outside of the base path are not changed.
+func stripBasePath(urlPath, basePath string) string {
+	prefix := strings.TrimSuffix(basePath, "/")
+	switch {
+	case len(prefix) == 0, !hasBasePath(urlPath, basePath):
+		return urlPath
+	case urlPath == prefix:
+		return "/"
+	}
+	return strings.TrimPrefix(urlPath, prefix)
+}
+
+// hasBasePath reports whether the url path is under the base path.
+func hasBasePath(urlPath, basePath string) bool {
+	prefix := strings.TrimSuffix(basePath, "/")
+	return len(prefix) == 0 || urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// withHTTPSRedirect permanently redirects requests that were not made or forwarded over https, if enabled.
+// The redirect is to the https port, which is the default port if empty.
+func withHTTPSRedirect(h http.Handler, enabled bool, httpsPort string) http.Handler {
+	if !enabled {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		u := "https://" + httpsHost(r.Host, httpsPort) + r.URL.RequestURI()
+		http.Redirect(w, r, u, http.StatusMovedPermanently)
+	})
+}
+
+// httpsHost replaces the port of the host with the https port, leaving it off if it is the default port.
+func httpsHost(hostPort, httpsPort string) string {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]") // no port
+	}
+	if len(httpsPort) == 0 || httpsPort == "443" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, httpsPort)
+}
+
+// withHiddenFiles responds with 404 Not Found for files or directories that start with a dot or an underscore, such as .htaccess and _headers.
+// Those are the configuration files of other hosts.  The files of /.well-known/, such as security.txt, are served.
+func withHiddenFiles(h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/.well-known/")
+		for _, name := range strings.Split(p, "/") {
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+func withPathSanitizer(h http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == src {
-			r.URL.Path = dest
+		p := r.URL.Path
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			p = unescaped // double percent-encoding
 		}
+		p = path.Clean("/" + p)
+		r.URL.Path = p
+		r.URL.RawPath = ""
 		h.ServeHTTP(w, r)
 	}
 }
 
-func withBasicCacheControl(h http.Handler) http.HandlerFunc {
-	day := 24 * time.Hour
-	year := 365 * day
+// withCanonicalHeader sets a Link header with the canonical url of html pages under the base path, like the canonical link of the pages.
+// The url is on the base url, not the host of the request, which might be an ip address or an alternate host.
+// The home page is served at the root of the site, so /home.html is linked as /.
+func withCanonicalHeader(h http.Handler, baseURL, basePath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ext := path.Ext(r.URL.Path)
-		h2 := withCacheControl(h, year)
-		switch ext {
+		p := stripBasePath(r.URL.Path, basePath)
+		switch {
+		case len(baseURL) == 0, !hasBasePath(r.URL.Path, basePath):
+		case path.Ext(p) == ".html", len(path.Ext(p)) == 0:
+			if p == "/home.html" {
+				p = "/"
+			}
+			u := strings.TrimSuffix(baseURL, "/") + strings.TrimSuffix(basePath, "/") + p
+			w.Header().Set("Link", "<"+u+">; rel=\"canonical\"")
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// withContentTypes sets the Content-Type of files with extensions that the operating system might not know.
+func withContentTypes(h http.Handler, contentTypes map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ct, ok := contentTypes[path.Ext(r.URL.Path)]; ok {
+			w.Header().Set("Content-Type", ct)
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+func withFeedCORS(h http.Handler, feedPaths []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(feedPaths, r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// withCORS allows the origins to make cross-origin requests, responding to preflight requests from them.
+func withCORS(h http.Handler, allowedOrigins []string) http.Handler {
+	if len(allowedOrigins) == 0 {
+		return h
+	}
+	allowAll := slices.Contains(allowedOrigins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !allowAll {
+			w.Header().Add("Vary", "Origin")
+		}
+		switch {
+		case len(origin) == 0:
+			h.ServeHTTP(w, r)
+			return
+		case allowAll:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case slices.Contains(allowedOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) != 0 {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// buildInfo describes the version of the server that is running.
+type buildInfo struct {
+	Status    string `json:"status"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+// healthPaths are the paths of the health check.  /health is kept for older deployments.
+var healthPaths = []string{"/healthz", "/health"}
+
+// withHealthCheck responds to the health paths without reading the site, including the build info if the verbose query parameter is set.
+func withHealthCheck(h http.Handler, info buildInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(healthPaths, r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Query().Get("verbose") == "1" {
+			info.Status = "ok"
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(info)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}
+}
+
+func withMaintenance(h http.Handler, page []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(healthPaths, r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(page)
+	}
+}
+
+// withCustom404 sends the page instead of the body of responses that are not found.
+func withCustom404(h http.Handler, page []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scw := statusCaptureWriter{
+			ResponseWriter: w,
+			page:           page,
+		}
+		h.ServeHTTP(&scw, r)
+	})
+}
+
+// statusCaptureWriter replaces the body of a 404 response with the page.
+type statusCaptureWriter struct {
+	http.ResponseWriter
+	page     []byte
+	notFound bool
+}
+
+func (scw *statusCaptureWriter) WriteHeader(statusCode int) {
+	if statusCode != http.StatusNotFound {
+		scw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	scw.notFound = true
+	scw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	scw.Header().Del("Content-Length")
+	scw.ResponseWriter.WriteHeader(statusCode)
+	scw.ResponseWriter.Write(scw.page)
+}
+
+func (scw *statusCaptureWriter) Write(p []byte) (n int, err error) {
+	if scw.notFound {
+		return len(p), nil // the page was written instead
+	}
+	return scw.ResponseWriter.Write(p)
+}
+
+// preloadResource is an external resource that the generator wrote to preload.json.
+type preloadResource struct {
+	URL         string `json:"url"`
+	As          string `json:"as"`
+	CrossOrigin string `json:"crossorigin"`
+}
+
+func withSecurityHeaders(h http.Handler, csp string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, sh := range headers.Security {
+			w.Header().Set(sh.Key, sh.Value)
+		}
+		w.Header().Set("Content-Security-Policy", csp)
+		h.ServeHTTP(w, r)
+	}
+}
+
+func withPreloadHints(h http.Handler, resources []preloadResource) http.HandlerFunc {
+	links := make([]string, len(resources))
+	for i, pr := range resources {
+		link := "<" + pr.URL + ">; rel=preload; as=" + pr.As
+		if len(pr.CrossOrigin) != 0 {
+			link += "; crossorigin=" + pr.CrossOrigin
+		}
+		links[i] = link
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch path.Ext(r.URL.Path) {
 		case ".html", "":
-			h2 = withCacheControl(h, day)
+			for _, link := range links {
+				w.Header().Add("Link", link)
+			}
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+func withBasicCacheControl(h http.Handler, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ext := path.Ext(stripBasePath(r.URL.Path, basePath))
+		h2 := withCacheControl(h, headers.StaticMaxAge)
+		if headers.IsPage(ext) {
+			h2 = withCacheControl(h, headers.HTMLMaxAge)
 		}
 		h2.ServeHTTP(w, r)
 	}
 }
 
 func withCacheControl(h http.Handler, d time.Duration) http.HandlerFunc {
-	maxAge := "max-age=" + strconv.Itoa(int(d.Seconds()))
+	maxAge := headers.MaxAge(d)
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Cache-Control", maxAge)
 		h.ServeHTTP(w, r)
 	}
 }
 
-func withContentEncoding(h http.Handler) http.HandlerFunc {
+// withETag sets an ETag header from a hash of html response bodies and responds with 304 Not Modified if the client has it.
+// Other responses, such as large pdfs and images, are passed through without being buffered.
+func withETag(h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			h.ServeHTTP(w, r)
+			return
+		}
+		brw := bufferedResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+		h.ServeHTTP(&brw, r)
+		if !brw.buffering {
+			if !brw.wroteHeader {
+				w.WriteHeader(brw.status)
+			}
+			return
+		}
+		hash := fnv.New64a()
+		hash.Write(brw.buf.Bytes())
+		etag := `"` + strconv.FormatUint(hash.Sum64(), 16) + `"`
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(brw.status)
+		w.Write(brw.buf.Bytes())
+	}
+}
+
+// etagMatches reports whether the If-None-Match header value includes the etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, s := range strings.Split(ifNoneMatch, ",") {
+		s = strings.TrimSpace(s)
+		s = strings.TrimPrefix(s, "W/")
+		if s == etag || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds the status and body of successful html responses so they can be inspected before being written.
+// Other responses are written through when the header is written.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	buffering   bool
+	wroteHeader bool
+	buf         bytes.Buffer
+}
+
+func (brw *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if brw.wroteHeader {
+		return
+	}
+	brw.wroteHeader = true
+	brw.status = statusCode
+	mediaType, _, _ := mime.ParseMediaType(brw.Header().Get("Content-Type"))
+	brw.buffering = statusCode == http.StatusOK && mediaType == "text/html" && len(brw.Header().Get("ETag")) == 0
+	if !brw.buffering {
+		brw.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (brw *bufferedResponseWriter) Write(p []byte) (n int, err error) {
+	if !brw.wroteHeader {
+		brw.WriteHeader(http.StatusOK)
+	}
+	if !brw.buffering {
+		return brw.ResponseWriter.Write(p)
+	}
+	return brw.buf.Write(p)
+}
+
+// withContentEncoding gzips responses for clients that accept it.
+// Files that have a precompressed copy in the file system, such as home.html.gz, are served from the copy instead.
+func withContentEncoding(h http.Handler, fSys fs.FS) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		enc := r.Header.Get("Accept-Encoding")
 		if strings.Contains(enc, "gzip") {
+			if serveGzipFile(w, r, fSys) {
+				return
+			}
 			gzw := gzip.NewWriter(w)
 			defer gzw.Close()
 			wrw := wrappedResponseWriter{
@@ -58,6 +444,178 @@ func withContentEncoding(h http.Handler) http.HandlerFunc {
 	}
 }
 
+// serveGzipFile serves the .gz copy of the requested file if it exists, returning false if it does not.
+func serveGzipFile(w http.ResponseWriter, r *http.Request, fSys fs.FS) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/") + ".gz"
+	f, err := fSys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+	if len(w.Header().Get("Content-Type")) == 0 {
+		ct := mime.TypeByExtension(path.Ext(r.URL.Path))
+		if len(ct) == 0 {
+			ct = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+	return true
+}
+
+func withRequestLog(h http.Handler, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// inner handlers rewrite the path of the request, such as / to /home.html
+		p := r.URL.Path
+		srw := statusResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+		h.ServeHTTP(&srw, r)
+		logger.Info("request",
+			"method", r.Method,
+			"path", p,
+			"status", srw.status,
+			"size", srw.size,
+			"latency", time.Since(start),
+		)
+	}
+}
+
+// statusResponseWriter records the status code and number of bytes written for a response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (srw *statusResponseWriter) WriteHeader(statusCode int) {
+	srw.status = statusCode
+	srw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (srw *statusResponseWriter) Write(p []byte) (n int, err error) {
+	n, err = srw.ResponseWriter.Write(p)
+	srw.size += n
+	return n, err
+}
+
+// withMaxBodySize responds with 413 Request Entity Too Large when the body of a POST or PUT request is larger than maxBytes.
+// The body is read before the handler is called so it is not partially handled.
+func withMaxBodySize(h http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > maxBytes {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withRateLimit responds with 429 Too Many Requests when an ip address makes requests faster than the rate.
+// Each address can make burst requests at once. Addresses are forgotten after five minutes without requests.
+func withRateLimit(h http.Handler, rps float64, burst int) http.Handler {
+	if rps <= 0 {
+		return h
+	}
+	var buckets sync.Map // ip address => *tokenBucket
+	var evictOnce sync.Once
+	evictStale := func() {
+		for range time.Tick(time.Minute) {
+			staleTime := time.Now().Add(-5 * time.Minute)
+			buckets.Range(func(key, value any) bool {
+				if value.(*tokenBucket).lastSeen().Before(staleTime) {
+					buckets.Delete(key)
+				}
+				return true
+			})
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evictOnce.Do(func() { go evictStale() })
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		v, _ := buckets.LoadOrStore(ip, newTokenBucket(rps, burst))
+		ok, wait := v.(*tokenBucket).take(time.Now())
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tokenBucket allows requests while it has tokens, which refill at a constant rate.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rps float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take removes a token from the bucket, or reports how long until a token is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (b *tokenBucket) lastSeen() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
 type wrappedResponseWriter struct {
 	io.Writer
 	http.ResponseWriter
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -23,7 +29,7 @@ func TestWithProxy(t *testing.T) {
 			h1 := func(w http.ResponseWriter, r *http.Request) {
 				w.Write([]byte(r.URL.Path))
 			}
-			h2 := withProxy(http.HandlerFunc(h1), "/replace", "/redirect")
+			h2 := withProxy(http.HandlerFunc(h1), "/", "/replace", "/redirect")
 			r := httptest.NewRequest("", test.url, nil)
 			w := httptest.NewRecorder()
 			h2.ServeHTTP(w, r)
@@ -34,6 +40,501 @@ func TestWithProxy(t *testing.T) {
 	}
 }
 
+func TestWithHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name         string
+		enabled      bool
+		host         string
+		httpsPort    string
+		proto        string
+		wantCode     int
+		wantLocation string
+	}{
+		{"redirect", true, "example.com", "", "http", 301, "https://example.com/a.html?b=c"},
+		{"redirect without proxy", true, "example.com", "", "", 301, "https://example.com/a.html?b=c"},
+		{"http port removed", true, "example.com:80", "", "", 301, "https://example.com/a.html?b=c"},
+		{"default https port", true, "example.com:80", "443", "", 301, "https://example.com/a.html?b=c"},
+		{"https port", true, "example.com:80", "8443", "", 301, "https://example.com:8443/a.html?b=c"},
+		{"ipv6", true, "[::1]:80", "8443", "", 301, "https://[::1]:8443/a.html?b=c"},
+		{"ipv6 without port", true, "[::1]", "", "", 301, "https://[::1]/a.html?b=c"},
+		{"already https", true, "example.com", "", "https", 200, ""},
+		{"disabled", false, "example.com", "", "http", 200, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withHTTPSRedirect(http.HandlerFunc(h1), test.enabled, test.httpsPort)
+			r := httptest.NewRequest("", "http://example.com/a.html?b=c", nil)
+			r.Host = test.host
+			if len(test.proto) != 0 {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantLocation, w.Header().Get("Location"); want != got {
+				t.Errorf("locations not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithProxyMap(t *testing.T) {
+	rewrites := map[string]string{
+		"/":         "/home.html",
+		"/old.html": "/new.html",
+		"/a":        "/b",
+		"/b":        "/a",
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/", "/home.html"},
+		{"/old.html", "/new.html"},
+		{"/new.html", "/new.html"},
+		{"/a", "/b"},
+		{"/b", "/a"},
+		{"/c", "/c"},
+	}
+	h1 := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}
+	h2 := withProxyMap(http.HandlerFunc(h1), "/", rewrites)
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Body.String(); got != want {
+				t.Errorf("wanted body to be %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithProxyMapBasePath(t *testing.T) {
+	rewrites := map[string]string{
+		"/": "/home.html",
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/enlighten", "/home.html"},
+		{"/enlighten/", "/home.html"},
+		{"/enlighten/home.html", "/home.html"},
+		{"/enlighten/images/a.png", "/images/a.png"},
+		{"/enlightened/home.html", "/enlightened/home.html"},
+		{"/home.html", "/home.html"},
+	}
+	h1 := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}
+	h2 := withProxyMap(http.HandlerFunc(h1), "/enlighten/", rewrites)
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Body.String(); got != want {
+				t.Errorf("wanted body to be %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestNewHandlerHiddenFiles(t *testing.T) {
+	siteFS := fstest.MapFS{
+		"build/site/.well-known/security.txt": &fstest.MapFile{Data: []byte("Contact: mailto:a@example.com")},
+		"build/site/.htaccess":                &fstest.MapFile{Data: []byte("ErrorDocument 404 /404.html")},
+		"build/site/_headers":                 &fstest.MapFile{Data: []byte("/*")},
+		"build/site/home.html":                &fstest.MapFile{Data: []byte("<p>home</p>")},
+	}
+	h, err := newHandler(config{basePath: "/"}, siteFS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("creating handler: %v", err)
+	}
+	tests := []struct {
+		path     string
+		wantCode int
+	}{
+		{"/.well-known/security.txt", 200},
+		{"/.htaccess", 404},
+		{"/_headers", 404},
+		{"/.well-known/.hidden", 404},
+		{"/home.html", 200},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.path, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestNewHandlerBasePath(t *testing.T) {
+	cfg := config{
+		basePath: "/enlighten/",
+		baseURL:  "https://example.com",
+	}
+	h, err := newHandler(cfg, _siteFS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("creating handler: %v", err)
+	}
+	tests := []struct {
+		name          string
+		path          string
+		wantCode      int
+		wantCORS      string
+		wantCanonical string
+	}{
+		{"root", "/enlighten/", 200, "", `<https://example.com/enlighten/>; rel="canonical"`},
+		{"home", "/enlighten/home.html", 200, "", `<https://example.com/enlighten/>; rel="canonical"`},
+		{"feed", "/enlighten/events/feed.atom", 200, "*", ""},
+		{"image", "/enlighten/images/enlighten-logo.png", 200, "", ""},
+		{"base path removed by proxy", "/contact-us.html", 200, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			r.Header.Set("Origin", "https://reader.example.org")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantCORS, w.Header().Get("Access-Control-Allow-Origin"); want != got {
+				t.Errorf("cors origins not equal: wanted %q, got %q", want, got)
+			}
+			if want, got := test.wantCanonical, w.Header().Get("Link"); want != got {
+				t.Errorf("canonical links not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithPathSanitizer(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/a/b", "/a/b"},
+		{"//a//b", "/a/b"},
+		{"/../a/b", "/a/b"},
+		{"/a/%2Fb", "/a/b"},
+		{"/a/%252Fb", "/a/b"},
+		{"/a/b/", "/a/b"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.URL.Path))
+			}
+			h2 := withPathSanitizer(http.HandlerFunc(h1))
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Body.String(); got != want {
+				t.Fatalf("wanted body to be %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithContentTypes(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/events/feed.atom", "application/atom+xml; charset=utf-8"},
+		{"/images/a.webp", "image/webp"},
+		{"/rss.xml", "text/xml; charset=utf-8"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			h1 := http.FileServer(http.FS(fstest.MapFS{
+				"events/feed.atom": &fstest.MapFile{Data: []byte("<feed></feed>")},
+				"images/a.webp":    &fstest.MapFile{Data: []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")},
+				"rss.xml":          &fstest.MapFile{Data: []byte("<rss></rss>")},
+			}))
+			h2 := withContentTypes(h1, contentTypes)
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Header().Get("Content-Type"); want != got {
+				t.Errorf("wanted Content-Type %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithFeedCORS(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantCode   int
+		wantOrigin string
+	}{
+		{"feed", "GET", "/rss.xml", 200, "*"},
+		{"feed preflight", "OPTIONS", "/rss.xml", 204, "*"},
+		{"other", "GET", "/home.html", 200, ""},
+		{"other preflight", "OPTIONS", "/home.html", 200, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withFeedCORS(http.HandlerFunc(h1), []string{"/rss.xml"})
+			r := httptest.NewRequest(test.method, test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("wanted status code %v, got %v", want, got)
+			}
+			if want, got := test.wantOrigin, w.Header().Get("Access-Control-Allow-Origin"); want != got {
+				t.Errorf("wanted Access-Control-Allow-Origin header %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		body          io.Reader
+		contentLength int64
+		wantCode      int
+		wantBody      string
+	}{
+		{"small post", "POST", strings.NewReader("hello"), 5, 200, "hello"},
+		{"exact post", "POST", strings.NewReader("1234567890"), 10, 200, "1234567890"},
+		{"large post", "POST", strings.NewReader("12345678901"), 11, 413, ""},
+		{"large put", "PUT", strings.NewReader("12345678901"), 11, 413, ""},
+		{"large post of unknown length", "POST", strings.NewReader("12345678901"), -1, 413, ""},
+		{"large get is not read", "GET", strings.NewReader("12345678901"), 11, 200, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handled := false
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+				if r.Method != http.MethodGet {
+					io.Copy(w, r.Body)
+				}
+			}
+			h2 := withMaxBodySize(http.HandlerFunc(h1), 10)
+			r := httptest.NewRequest(test.method, "/contact-us.html", test.body)
+			r.ContentLength = test.contentLength
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantCode == 200, handled; want != got {
+				t.Errorf("wanted handler to be called: %v, got %v", want, got)
+			}
+			if test.wantCode == 200 && test.wantBody != w.Body.String() {
+				t.Errorf("bodies not equal: wanted %q, got %q", test.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowedOrigins  []string
+		method          string
+		origin          string
+		wantCode        int
+		wantAllowOrigin string
+		wantMethods     string
+	}{
+		{"matching origin", []string{"https://a.example", "https://b.example"}, "GET", "https://b.example", 200, "https://b.example", ""},
+		{"non-matching origin", []string{"https://a.example"}, "GET", "https://evil.example", 200, "", ""},
+		{"no origin", []string{"https://a.example"}, "GET", "", 200, "", ""},
+		{"wildcard", []string{"*"}, "GET", "https://c.example", 200, "*", ""},
+		{"preflight", []string{"https://a.example"}, "OPTIONS", "https://a.example", 204, "https://a.example", "GET, HEAD, OPTIONS"},
+		{"preflight non-matching origin", []string{"https://a.example"}, "OPTIONS", "https://evil.example", 200, "", ""},
+		{"disabled", nil, "GET", "https://a.example", 200, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withCORS(http.HandlerFunc(h1), test.allowedOrigins)
+			r := httptest.NewRequest(test.method, "/resources/events/2023/a.pdf", nil)
+			if len(test.origin) != 0 {
+				r.Header.Set("Origin", test.origin)
+			}
+			if test.method == http.MethodOptions {
+				r.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantAllowOrigin, w.Header().Get("Access-Control-Allow-Origin"); want != got {
+				t.Errorf("allowed origins not equal: wanted %q, got %q", want, got)
+			}
+			if want, got := test.wantMethods, w.Header().Get("Access-Control-Allow-Methods"); want != got {
+				t.Errorf("allowed methods not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithHealthCheck(t *testing.T) {
+	info := buildInfo{
+		BuildTime: "2023-08-01T12:00:00Z",
+		Commit:    "abc123",
+	}
+	h1 := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("health check should not reach site handler")
+	}
+	h2 := withHealthCheck(http.HandlerFunc(h1), info)
+	for _, p := range healthPaths {
+		t.Run(p, func(t *testing.T) {
+			r := httptest.NewRequest("", p, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := 200, w.Code; want != got {
+				t.Errorf("wanted status code %v, got %v", want, got)
+			}
+			if want, got := "ok", w.Body.String(); want != got {
+				t.Errorf("wanted body to be %q, got %q", want, got)
+			}
+			if want, got := "text/plain", w.Header().Get("Content-Type"); want != got {
+				t.Errorf("wanted Content-Type %q, got %q", want, got)
+			}
+		})
+	}
+	t.Run("verbose", func(t *testing.T) {
+		r := httptest.NewRequest("", "/healthz?verbose=1", nil)
+		w := httptest.NewRecorder()
+		h2.ServeHTTP(w, r)
+		if want, got := 200, w.Code; want != got {
+			t.Errorf("wanted status code %v, got %v", want, got)
+		}
+		var got buildInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("parsing body %q: %v", w.Body.String(), err)
+		}
+		want := info
+		want.Status = "ok"
+		if want != got {
+			t.Errorf("not equal: \n wanted: %#v \n got:    %#v", want, got)
+		}
+	})
+}
+
+func TestWithMaintenance(t *testing.T) {
+	page := "DOWN_FOR_MAINTENANCE"
+	tests := []struct {
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"/home.html", 503, page},
+		{"/health", 200, "OK"},
+		{"/healthz", 200, "OK"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withMaintenance(http.HandlerFunc(h1), []byte(page))
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("wanted status code %v, got %v", want, got)
+			}
+			if want, got := test.wantBody, w.Body.String(); want != got {
+				t.Errorf("wanted body to be %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithSecurityHeaders(t *testing.T) {
+	fSys := fstest.MapFS{
+		"a.html": &fstest.MapFile{Data: []byte("<p>a</p>")},
+		"b.png":  &fstest.MapFile{Data: []byte("png")},
+	}
+	csp := "default-src 'self'"
+	h := withSecurityHeaders(http.FileServer(http.FS(fSys)), csp)
+	want := map[string]string{
+		"X-Frame-Options":         "SAMEORIGIN",
+		"X-Content-Type-Options":  "nosniff",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Content-Security-Policy": csp,
+	}
+	for _, url := range []string{"/a.html", "/b.png"} {
+		t.Run(url, func(t *testing.T) {
+			r := httptest.NewRequest("", url, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := http.StatusOK, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			for k, v := range want {
+				if got := w.Header().Get(k); v != got {
+					t.Errorf("wanted %v header to be %q, got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithPreloadHints(t *testing.T) {
+	resources := []preloadResource{
+		{URL: "https://fonts.example.com/a.css", As: "style"},
+		{URL: "https://fonts.example.com/b.woff2", As: "font", CrossOrigin: "anonymous"},
+	}
+	wantLinks := []string{
+		"<https://fonts.example.com/a.css>; rel=preload; as=style",
+		"<https://fonts.example.com/b.woff2>; rel=preload; as=font; crossorigin=anonymous",
+	}
+	tests := []struct {
+		url       string
+		wantLinks []string
+	}{
+		{"/", wantLinks},
+		{"/about.html", wantLinks},
+		{"/images/logo.png", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withPreloadHints(http.HandlerFunc(h1), resources)
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantLinks, w.Header().Values("Link"); !slices.Equal(want, got) {
+				t.Errorf("wanted Link headers %q, got %q", want, got)
+			}
+		})
+	}
+}
+
 func TestWithCacheControl(t *testing.T) {
 	msg := "OK_1549"
 	h1 := func(w http.ResponseWriter, r *http.Request) {
@@ -52,12 +553,83 @@ func TestWithCacheControl(t *testing.T) {
 	}
 }
 
+func TestWithCanonicalHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		basePath string
+		target   string
+		want     string
+	}{
+		{"root", "https://example.com", "/", "http://example.com/", `<https://example.com/>; rel="canonical"`},
+		{"home", "https://example.com/", "/", "http://example.com/home.html", `<https://example.com/>; rel="canonical"`},
+		{"page", "https://example.com", "/", "http://example.com/events/past-events.html?a=b", `<https://example.com/events/past-events.html>; rel="canonical"`},
+		{"ip address", "https://example.com", "/", "http://127.0.0.1:8000/contact-us.html", `<https://example.com/contact-us.html>; rel="canonical"`},
+		{"spoofed host", "https://example.com", "/", "http://evil.example/contact-us.html", `<https://example.com/contact-us.html>; rel="canonical"`},
+		{"image", "https://example.com", "/", "http://example.com/images/a.png", ""},
+		{"no base url", "", "/", "http://example.com/contact-us.html", ""},
+		{"home under base path", "https://example.com", "/enlighten/", "http://example.com/enlighten/home.html", `<https://example.com/enlighten/>; rel="canonical"`},
+		{"page under base path", "https://example.com", "/enlighten/", "http://example.com/enlighten/contact-us.html", `<https://example.com/enlighten/contact-us.html>; rel="canonical"`},
+		{"page not under base path", "https://example.com", "/enlighten/", "http://example.com/contact-us.html", ""},
+		{"page under similar path", "https://example.com", "/enlighten/", "http://example.com/enlightened/contact-us.html", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			}
+			h2 := withCanonicalHeader(http.HandlerFunc(h1), test.baseURL, test.basePath)
+			r := httptest.NewRequest("GET", test.target, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Header().Get("Link"); want != got {
+				t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithCustom404(t *testing.T) {
+	page := []byte("<h1>Page Not Found</h1>")
+	fSys := fstest.MapFS{
+		"home.html": &fstest.MapFile{Data: []byte("home")},
+	}
+	h := withCustom404(http.FileServer(http.FS(fSys)), page)
+	tests := []struct {
+		name            string
+		path            string
+		wantCode        int
+		wantBody        string
+		wantContentType string
+	}{
+		{"found", "/home.html", 200, "home", "text/html; charset=utf-8"},
+		{"not found", "/missing.html", 404, string(page), "text/html; charset=utf-8"},
+		{"not found directory", "/events/", 404, string(page), "text/html; charset=utf-8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.path, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantBody, w.Body.String(); want != got {
+				t.Errorf("bodies not equal: wanted %q, got %q", want, got)
+			}
+			if want, got := test.wantContentType, w.Header().Get("Content-Type"); want != got {
+				t.Errorf("content types not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
 func TestWithBasicCacheControl(t *testing.T) {
 	msg := "once"
 	h1 := func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(msg))
 	}
-	h2 := withBasicCacheControl(http.HandlerFunc(h1))
+	h2 := withBasicCacheControl(http.HandlerFunc(h1), "/")
 	r1 := httptest.NewRequest("", "/", nil)
 	w1 := httptest.NewRecorder()
 	h2.ServeHTTP(w1, r1)
@@ -71,6 +643,332 @@ func TestWithBasicCacheControl(t *testing.T) {
 	}
 }
 
+func TestWithBasicCacheControlBasePath(t *testing.T) {
+	h1 := func(w http.ResponseWriter, r *http.Request) {}
+	h2 := withBasicCacheControl(http.HandlerFunc(h1), "/v1.2/")
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/v1.2", "max-age=86400"},
+		{"/v1.2/home.html", "max-age=86400"},
+		{"/v1.2/images/a.png", "max-age=31536000"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			r := httptest.NewRequest("", test.url, nil)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.want, w.Header().Get("Cache-Control"); want != got {
+				t.Errorf("not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithETag(t *testing.T) {
+	msg := "<p>OK_etag</p>"
+	h1 := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(msg))
+	}
+	h2 := withETag(http.HandlerFunc(h1))
+	r1 := httptest.NewRequest("", "/", nil)
+	w1 := httptest.NewRecorder()
+	h2.ServeHTTP(w1, r1)
+	if want, got := http.StatusOK, w1.Code; want != got {
+		t.Fatalf("status codes not equal: wanted %v, got %v", want, got)
+	}
+	if want, got := msg, w1.Body.String(); want != got {
+		t.Errorf("bodies not equal: wanted %q, got %q", want, got)
+	}
+	etag := w1.Header().Get("ETag")
+	if len(etag) == 0 {
+		t.Fatalf("missing ETag header")
+	}
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+		wantBody    string
+	}{
+		{"match", etag, http.StatusNotModified, ""},
+		{"weak match", "W/" + etag, http.StatusNotModified, ""},
+		{"list match", `"other", ` + etag, http.StatusNotModified, ""},
+		{"no match", `"other"`, http.StatusOK, msg},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("", "/", nil)
+			r.Header.Set("If-None-Match", test.ifNoneMatch)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := test.wantCode, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantBody, w.Body.String(); want != got {
+				t.Errorf("bodies not equal: wanted %q, got %q", want, got)
+			}
+			if want, got := etag, w.Header().Get("ETag"); want != got {
+				t.Errorf("ETags not equal: wanted %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestWithETagPassThrough(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+	}{
+		{"pdf", "application/pdf", http.StatusOK},
+		{"image", "image/jpeg", http.StatusOK},
+		{"not found html", "text/html; charset=utf-8", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h1 := func(w2 http.ResponseWriter, r *http.Request) {
+				w2.Header().Set("Content-Type", test.contentType)
+				w2.WriteHeader(test.status)
+				w2.Write([]byte("first"))
+				if got := w.Body.String(); got != "first" {
+					t.Errorf("wanted body to be written before the handler finished, got %q", got)
+				}
+				w2.Write([]byte("second"))
+			}
+			h2 := withETag(http.HandlerFunc(h1))
+			r := httptest.NewRequest("", "/", nil)
+			h2.ServeHTTP(w, r)
+			if want, got := test.status, w.Code; want != got {
+				t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+			}
+			if want, got := "firstsecond", w.Body.String(); want != got {
+				t.Errorf("bodies not equal: wanted %q, got %q", want, got)
+			}
+			if got := w.Header().Values("ETag"); len(got) != 0 {
+				t.Errorf("wanted no ETag, got %q", got)
+			}
+		})
+	}
+}
+
+func TestWithETagFileServer(t *testing.T) {
+	fSys := fstest.MapFS{
+		"a.html": &fstest.MapFile{Data: []byte("<p>a</p>"), ModTime: time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	h := withETag(http.FileServer(http.FS(fSys)))
+	r1 := httptest.NewRequest("", "/a.html", nil)
+	r1.Header.Set("If-Modified-Since", "Tue, 01 Aug 2023 00:00:00 GMT")
+	w1 := httptest.NewRecorder()
+	h.ServeHTTP(w1, r1)
+	if want, got := http.StatusNotModified, w1.Code; want != got {
+		t.Fatalf("wanted file server to handle If-Modified-Since with status %v, got %v", want, got)
+	}
+	if got := w1.Header().Values("ETag"); len(got) != 0 {
+		t.Errorf("wanted no ETag for response not modified by file server, got %q", got)
+	}
+	r2 := httptest.NewRequest("", "/a.html", nil)
+	w2 := httptest.NewRecorder()
+	h.ServeHTTP(w2, r2)
+	if want, got := 1, len(w2.Header().Values("ETag")); want != got {
+		t.Errorf("wanted %v ETag header, got %v", want, got)
+	}
+	if want, got := "<p>a</p>", w2.Body.String(); want != got {
+		t.Errorf("bodies not equal: wanted %q, got %q", want, got)
+	}
+}
+
+func TestWithContentEncodingPrecompressed(t *testing.T) {
+	var gz bytes.Buffer
+	gzw := gzip.NewWriter(&gz)
+	gzw.Write([]byte("<p>precompressed</p>"))
+	gzw.Close()
+	fSys := fstest.MapFS{
+		"home.html":    &fstest.MapFile{Data: []byte("<p>precompressed</p>")},
+		"home.html.gz": &fstest.MapFile{Data: gz.Bytes()},
+		"about.html":   &fstest.MapFile{Data: []byte("<p>about</p>")},
+	}
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		acceptEncoding  string
+		wantPrecomp     bool
+		wantCE          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"precompressed", "GET", "/home.html", "gzip", true, "gzip", "text/html; charset=utf-8", "<p>precompressed</p>"},
+		{"on the fly", "GET", "/about.html", "gzip", false, "gzip", "", "<p>about</p>"},
+		{"not accepted", "GET", "/home.html", "br", false, "", "", "<p>precompressed</p>"},
+		{"post", "POST", "/home.html", "gzip", false, "gzip", "", "<p>precompressed</p>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handled := false
+			h1 := func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+				http.FileServer(http.FS(fSys)).ServeHTTP(w, r)
+			}
+			h2 := withContentEncoding(http.HandlerFunc(h1), fSys)
+			r := httptest.NewRequest(test.method, test.path, nil)
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			w := httptest.NewRecorder()
+			h2.ServeHTTP(w, r)
+			if want, got := !test.wantPrecomp, handled; want != got {
+				t.Errorf("wanted inner handler to be called: %v, got %v", want, got)
+			}
+			if want, got := test.wantCE, w.Header().Get("Content-Encoding"); want != got {
+				t.Errorf("content encodings not equal: wanted %q, got %q", want, got)
+			}
+			if want, got := test.wantContentType, w.Header().Get("Content-Type"); len(want) != 0 && want != got {
+				t.Errorf("content types not equal: wanted %q, got %q", want, got)
+			}
+			var body io.Reader = w.Body
+			if len(test.wantCE) != 0 {
+				gr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("creating gzip reader: %v", err)
+				}
+				body = gr
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if test.wantBody != string(got) {
+				t.Errorf("bodies not equal: wanted %q, got %q", test.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestWithRequestLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		h          http.HandlerFunc
+		wantStatus string
+		wantSize   string
+	}{
+		{
+			name:   "ok",
+			method: http.MethodGet,
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK_log"))
+			},
+			wantStatus: "status=200",
+			wantSize:   "size=6",
+		},
+		{
+			name:   "no body",
+			method: http.MethodDelete,
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			},
+			wantStatus: "status=405",
+			wantSize:   "size=0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			h := withRequestLog(test.h, logger)
+			r := httptest.NewRequest(test.method, "/a.html", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			got := buf.String()
+			for _, want := range []string{"method=" + test.method, "path=/a.html", test.wantStatus, test.wantSize, "latency="} {
+				if !strings.Contains(got, want) {
+					t.Errorf("wanted log to contain %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHandlerRequestLogPath(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	h, err := newHandler(config{basePath: "/"}, _siteFS, logger)
+	if err != nil {
+		t.Fatalf("creating handler: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	got := buf.String()
+	if want := "path=/ "; !strings.Contains(got, want) {
+		t.Errorf("wanted log to contain %q, got %q", want, got)
+	}
+	if notWant := "/home.html"; strings.Contains(got, notWant) {
+		t.Errorf("wanted log to not contain rewritten path %q, got %q", notWant, got)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	burst := 3
+	h1 := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}
+	h2 := withRateLimit(http.HandlerFunc(h1), 0.01, burst)
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("", "/", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		h2.ServeHTTP(w, r)
+		return w
+	}
+	for i := 0; i < burst; i++ {
+		if want, got := 200, request("192.0.2.1:1234").Code; want != got {
+			t.Fatalf("request %v: wanted status code %v, got %v", i, want, got)
+		}
+	}
+	w := request("192.0.2.1:5678")
+	if want, got := http.StatusTooManyRequests, w.Code; want != got {
+		t.Errorf("wanted status code %v after burst, got %v", want, got)
+	}
+	if want, got := "100", w.Header().Get("Retry-After"); want != got {
+		t.Errorf("wanted Retry-After %q, got %q", want, got)
+	}
+	if want, got := 200, request("192.0.2.2:1234").Code; want != got {
+		t.Errorf("wanted other ip address to not be limited with status code %v, got %v", want, got)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, 1)
+	now := b.last
+	if ok, _ := b.take(now); !ok {
+		t.Fatalf("wanted first token")
+	}
+	ok, wait := b.take(now)
+	if ok {
+		t.Fatalf("wanted bucket to be empty")
+	}
+	if want, got := 500*time.Millisecond, wait; want != got {
+		t.Errorf("wanted to wait %v, got %v", want, got)
+	}
+	if ok, _ := b.take(now.Add(wait)); !ok {
+		t.Errorf("wanted token after waiting")
+	}
+}
+
+func TestWithRateLimitDisabled(t *testing.T) {
+	h1 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h2 := withRateLimit(h1, 0, 1)
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		h2.ServeHTTP(w, httptest.NewRequest("", "/", nil))
+		if want, got := 200, w.Code; want != got {
+			t.Fatalf("wanted status code %v, got %v", want, got)
+		}
+	}
+}
+
 func TestWithContentEncoding(t *testing.T) {
 	msg := "OK_gzip"
 	tests := []struct {
@@ -106,7 +1004,7 @@ func TestWithContentEncoding(t *testing.T) {
 			h1 := func(w http.ResponseWriter, r *http.Request) {
 				w.Write([]byte(msg))
 			}
-			h2 := withContentEncoding(http.HandlerFunc(h1))
+			h2 := withContentEncoding(http.HandlerFunc(h1), fstest.MapFS{})
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest("", "/", nil)
 			r.Header.Add("Accept-Encoding", test.ae)
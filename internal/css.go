@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stylesheets are the main stylesheets, which are inlined on each page.
+var stylesheets = []string{"index.css", "nav.css"}
+
+// validateCSS scans the stylesheet for unterminated comments and strings and unbalanced brackets.
+func (*Site) validateCSS(name string, data []byte) error {
+	type opener struct {
+		b    byte
+		line int
+	}
+	closers := map[byte]byte{'}': '{', ')': '(', ']': '['}
+	var stack []opener
+	line := 1
+	for i := 0; i < len(data); i++ {
+		switch b := data[i]; b {
+		case '\n':
+			line++
+		case '\\':
+			i++ // escaped character
+		case '/':
+			if i+1 >= len(data) || data[i+1] != '*' {
+				continue
+			}
+			start := line
+			for i += 2; i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/'); i++ {
+				if data[i] == '\n' {
+					line++
+				}
+			}
+			if i+1 >= len(data) {
+				return fmt.Errorf("%v: unterminated comment starting on line %v", name, start)
+			}
+			i++
+		case '"', '\'':
+			for i++; i < len(data) && data[i] != b; i++ {
+				switch data[i] {
+				case '\\':
+					i++
+				case '\n':
+					return fmt.Errorf("%v: unterminated string on line %v", name, line)
+				}
+			}
+			if i >= len(data) {
+				return fmt.Errorf("%v: unterminated string on line %v", name, line)
+			}
+		case '{', '(', '[':
+			stack = append(stack, opener{b, line})
+		case '}', ')', ']':
+			n := len(stack)
+			if n == 0 || stack[n-1].b != closers[b] {
+				return fmt.Errorf("%v: unexpected %q on line %v", name, b, line)
+			}
+			stack = stack[:n-1]
+		}
+	}
+	if n := len(stack); n != 0 {
+		last := stack[n-1]
+		return fmt.Errorf("%v: unexpected end of file, %q on line %v is not closed", name, last.b, last.line)
+	}
+	return nil
+}
+
+// validateStylesheets checks the stylesheets that are included on every page.
+func (s *Site) validateStylesheets() error {
+	for _, name := range stylesheets {
+		data, err := fs.ReadFile(s.fSys, path.Join(resources, name))
+		if err != nil {
+			return fmt.Errorf("reading stylesheet: %w", err)
+		}
+		if err := s.validateCSS(name, data); err != nil {
+			return fmt.Errorf("validating stylesheet: %w", err)
+		}
+	}
+	return nil
+}
+
+var (
+	cssRuleRE     = regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+	cssCommentRE  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssHexColorRE = regexp.MustCompile(`#[0-9a-fA-F]{6}\b|#[0-9a-fA-F]{3}\b`)
+)
+
+// addDarkModeCSS writes dark-mode.css, which overrides the declarations of the main stylesheets that use a theme color.
+// The lightness of each theme color is inverted.
+func (s *Site) addDarkModeCSS() error {
+	themeColors := make(map[string]string, len(s.ThemeColors))
+	for _, c := range s.ThemeColors {
+		dark, err := invertLightness(c)
+		if err != nil {
+			return fmt.Errorf("inverting theme color: %w", err)
+		}
+		themeColors[expandHex(c)] = dark
+	}
+	var buf bytes.Buffer
+	buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+	for _, name := range stylesheets {
+		data, err := fs.ReadFile(s.fSys, path.Join(resources, name))
+		if err != nil {
+			return fmt.Errorf("reading stylesheet: %w", err)
+		}
+		data = cssCommentRE.ReplaceAll(data, nil)
+		for _, m := range cssRuleRE.FindAllSubmatch(data, -1) {
+			var decls []string
+			for _, d := range strings.Split(string(m[2]), ";") {
+				d = strings.TrimSpace(d)
+				replaced := false
+				d = cssHexColorRE.ReplaceAllStringFunc(d, func(c string) string {
+					dark, ok := themeColors[expandHex(c)]
+					if !ok {
+						return c
+					}
+					replaced = true
+					return dark
+				})
+				if replaced {
+					decls = append(decls, d)
+				}
+			}
+			if len(decls) == 0 {
+				continue
+			}
+			selector := strings.Join(strings.Fields(string(m[1])), " ")
+			fmt.Fprintf(&buf, "\t%v {\n", selector)
+			for _, d := range decls {
+				fmt.Fprintf(&buf, "\t\t%v;\n", d)
+			}
+			buf.WriteString("\t}\n")
+		}
+	}
+	buf.WriteString("}\n")
+	dest := s.fingerprint(path.Join(s.dest, "dark-mode.css"), buf.Bytes())
+	if err := s.checkFilenameLen(dest); err != nil {
+		return err
+	}
+	if err := s.writeFile(dest, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing dark mode stylesheet: %w", err)
+	}
+	return nil
+}
+
+// invertLightness converts the hex color to HSL, replaces its lightness l with 1-l, and converts it back to hex.
+func invertLightness(hex string) (string, error) {
+	h := expandHex(hex)
+	if len(h) != 7 || h[0] != '#' {
+		return "", fmt.Errorf("%q is not a hex color", hex)
+	}
+	v, err := strconv.ParseUint(h[1:], 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a hex color: %w", hex, err)
+	}
+	r, g, b := float64(v>>16&0xff)/255, float64(v>>8&0xff)/255, float64(v&0xff)/255
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	var hue, sat float64
+	if d := max - min; d != 0 {
+		sat = d / (1 - math.Abs(2*l-1))
+		switch max {
+		case r:
+			hue = math.Mod((g-b)/d, 6)
+		case g:
+			hue = (b-r)/d + 2
+		default:
+			hue = (r-g)/d + 4
+		}
+		hue *= 60
+		if hue < 0 {
+			hue += 360
+		}
+	}
+	l = 1 - l
+	c := (1 - math.Abs(2*l-1)) * sat
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := l - c/2
+	var r1, g1, b1 float64
+	switch {
+	case hue < 60:
+		r1, g1, b1 = c, x, 0
+	case hue < 120:
+		r1, g1, b1 = x, c, 0
+	case hue < 180:
+		r1, g1, b1 = 0, c, x
+	case hue < 240:
+		r1, g1, b1 = 0, x, c
+	case hue < 300:
+		r1, g1, b1 = x, 0, c
+	default:
+		r1, g1, b1 = c, 0, x
+	}
+	to255 := func(f float64) int { return int(math.Round((f + m) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", to255(r1), to255(g1), to255(b1)), nil
+}
+
+// expandHex lowercases the color, expanding it from the three digit form to the six digit form.
+func expandHex(c string) string {
+	c = strings.ToLower(c)
+	if len(c) == 4 && c[0] == '#' {
+		c = string([]byte{'#', c[1], c[1], c[2], c[2], c[3], c[3]})
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateCSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		css    string
+		wantOk bool
+	}{
+		{"empty", "", true},
+		{"valid", "body {\n\tmargin: 0;\n}\n.a::before { content: \"}\"; }", true},
+		{"nested", "@media (max-width: 50em) { a[href] { color: red; } }", true},
+		{"comment", "/* { */ a {}", true},
+		{"escaped brace", `.a\{ {}`, true},
+		{"unclosed block", "body {\n\tmargin: 0;\n", false},
+		{"extra closing brace", "body {}}", false},
+		{"mismatched bracket", "a[href} {}", false},
+		{"unterminated comment", "a {} /* oops", false},
+		{"unterminated string", "a { content: \"oops; }", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			err := s.validateCSS("test.css", []byte(test.css))
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddDarkModeCSS(t *testing.T) {
+	fSys := fstest.MapFS{
+		"resources/index.css": &fstest.MapFile{Data: []byte("body {\n\tmargin: 0;\n\tbackground-color: #FFF;\n}\nheader,\nfooter {\n\tcolor: #262626;\n}\nh3 {\n\tcolor: red;\n}\n")},
+		"resources/nav.css":   &fstest.MapFile{Data: []byte("/* #fff */\nnav { border: 1px solid #00f; }")},
+	}
+	s := newTestSite(fSys)
+	s.ThemeColors = []string{"#fff", "#262626", "#0000ff"}
+	if err := s.addDarkModeCSS(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := "@media (prefers-color-scheme: dark) {\n" +
+		"\tbody {\n\t\tbackground-color: #000000;\n\t}\n" +
+		"\theader, footer {\n\t\tcolor: #d9d9d9;\n\t}\n" +
+		"\tnav {\n\t\tborder: 1px solid #0000ff;\n\t}\n" +
+		"}\n"
+	got := string(s.files["dest/dark-mode.css"])
+	if !strings.Contains(got, "@media (prefers-color-scheme: dark)") {
+		t.Errorf("wanted dark mode media query, got %q", got)
+	}
+	if want != got {
+		t.Errorf("dark mode stylesheets not equal:\nwanted: %q\ngot:    %q", want, got)
+	}
+}
+
+func TestInvertLightness(t *testing.T) {
+	tests := []struct {
+		color  string
+		want   string
+		wantOk bool
+	}{
+		{"#ffffff", "#000000", true},
+		{"#000", "#ffffff", true},
+		{"#262626", "#d9d9d9", true},
+		{"#ff0000", "#ff0000", true},
+		{"#800000", "#ff7f7f", true},
+		{"ffffff", "", false},
+		{"#ggg", "", false},
+		{"#12345", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.color, func(t *testing.T) {
+			got, err := invertLightness(test.color)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != got:
+				t.Errorf("wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
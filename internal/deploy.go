@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"enlightenkitsap.org/internal/headers"
+)
+
+// compressedExts are the extensions of the files that are also written gzipped.
+var compressedExts = []string{".html", ".css", ".js"}
+
+// compressWrites also writes a gzipped copy of the html, css, and js files the site writes.
+func (s *Site) compressWrites() {
+	writeFile := s.writeFile
+	s.writeFile = func(name string, data []byte) error {
+		if err := writeFile(name, data); err != nil {
+			return err
+		}
+		if !slices.Contains(compressedExts, path.Ext(name)) {
+			return nil
+		}
+		return s.addCompressedVariant(writeFile, name, data)
+	}
+}
+
+// addCompressedVariant writes the data gzipped with the best compression to the name with a .gz extension, such as home.html.gz.
+func (*Site) addCompressedVariant(writeFile func(name string, data []byte) error, name string, data []byte) error {
+	var buf bytes.Buffer
+	gzw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("creating gzip writer: %w", err)
+	}
+	if _, err := gzw.Write(data); err != nil {
+		return fmt.Errorf("compressing %v: %w", name, err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("compressing %v: %w", name, err)
+	}
+	if err := writeFile(name+".gz", buf.Bytes()); err != nil {
+		return fmt.Errorf("writing compressed %v: %w", name, err)
+	}
+	return nil
+}
+
+// addDeployFiles writes the configuration files for hosting the site without the server.
+func (s *Site) addDeployFiles() error {
+	files := []struct {
+		enabled bool
+		name    string
+		add     func() error
+	}{
+		{s.GenerateHtaccess, ".htaccess", s.addHtaccess},
+		{s.GenerateNetlifyRedirects, "_redirects", func() error {
+			return s.addNetlifyRedirects(s.redirectRules())
+		}},
+		{s.GenerateVercelConfig, "vercel.json", s.addVercelConfig},
+		{s.GenerateLighthouseConfig, ".lighthouserc.json", s.addLighthouseConfig},
+		{s.GenerateCloudflareHeaders, "_headers", s.addCloudflareHeaders},
+		{s.GenerateDockerfile, "Dockerfile", s.addDockerfile},
+		{s.GenerateGHAWorkflow, "deploy.yml", s.addGitHubActionsWorkflow},
+	}
+	for _, f := range files {
+		if !f.enabled {
+			continue
+		}
+		if err := f.add(); err != nil {
+			return fmt.Errorf("adding %v: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Site) addDeployFile(name string, data []byte) error {
+	return s.writeDeployFile(path.Join(s.dest, name), data)
+}
+
+// addRepoFile writes a file to the repository instead of the site, so it is not served.
+func (s *Site) addRepoFile(name string, data []byte) error {
+	return s.writeDeployFile(path.Join(s.RepoDir, name), data)
+}
+
+func (s *Site) writeDeployFile(dest string, data []byte) error {
+	if err := s.mkdirAll(path.Dir(dest)); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	return nil
+}
+
+// RedirectRule sends requests for one path to another.  Rules with a 200 code are rewrites.
+type RedirectRule struct {
+	From string
+	To   string
+	Code int
+}
+
+// redirectRules are the home page rewrite rule followed by the sorted redirects of the site.
+func (s *Site) redirectRules() []RedirectRule {
+	rules := []RedirectRule{
+		{"/", "/home.html", http.StatusOK},
+	}
+	froms := make([]string, 0, len(s.RedirectMap))
+	for from := range s.RedirectMap {
+		froms = append(froms, from)
+	}
+	slices.Sort(froms)
+	for _, from := range froms {
+		r := RedirectRule{from, s.RedirectMap[from], http.StatusMovedPermanently}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// addHtaccess writes Apache rules that behave like the server.
+func (s *Site) addHtaccess() error {
+	data := fmt.Sprintf(`RewriteEngine On
+RewriteRule ^$ /home.html [L]
+
+<IfModule mod_deflate.c>
+	AddOutputFilterByType DEFLATE text/html text/css text/plain text/xml application/xml application/json application/javascript
+</IfModule>
+
+<IfModule mod_headers.c>
+%v	Header set Cache-Control "%v"
+	<FilesMatch "\.html$">
+		Header set Cache-Control "%v"
+	</FilesMatch>
+</IfModule>
+`, s.htaccessSecurityHeaders(), headers.MaxAge(headers.StaticMaxAge), headers.MaxAge(headers.HTMLMaxAge))
+	return s.addDeployFile(".htaccess", []byte(data))
+}
+
+func (s *Site) htaccessSecurityHeaders() string {
+	var sb strings.Builder
+	for _, h := range s.securityHeaders() {
+		fmt.Fprintf(&sb, "\tHeader set %v %q\n", h.Key, h.Value)
+	}
+	return sb.String()
+}
+
+// securityHeaders are the security headers of the server, including the Content-Security-Policy if it is set.
+func (s *Site) securityHeaders() []headers.Header {
+	hs := slices.Clone(headers.Security)
+	if len(s.ContentSecurityPolicy) != 0 {
+		hs = append(hs, headers.Header{Key: "Content-Security-Policy", Value: s.ContentSecurityPolicy})
+	}
+	return hs
+}
+
+// addNetlifyRedirects writes the rules in the Netlify redirects format.
+func (s *Site) addNetlifyRedirects(rules []RedirectRule) error {
+	var sb strings.Builder
+	for _, r := range rules {
+		fmt.Fprintf(&sb, "%v %v %v\n", r.From, r.To, r.Code)
+	}
+	return s.addDeployFile("_redirects", []byte(sb.String()))
+}
+
+// dockerfile builds the server in one stage and runs it in a distroless stage.
+// Distroless images have no shell, so a static wget is copied from busybox for the health check.
+const dockerfile = `# build the server
+FROM golang:1.21-alpine3.18 AS BUILDER
+WORKDIR /app
+COPY . ./
+RUN \
+    go generate && \
+    go test ./... && \
+    CGO_ENABLED=0 go build -o enlightenkitsap
+
+# get a static wget for the health check
+FROM busybox:1.36-musl AS BUSYBOX
+
+# copy the server to a minimal runtime image
+FROM gcr.io/distroless/static-debian12
+WORKDIR /app
+COPY --from=BUILDER /app/enlightenkitsap ./
+COPY --from=BUSYBOX /bin/wget /bin/wget
+ENV PORT 8000
+EXPOSE 8000
+HEALTHCHECK --interval=30s --timeout=3s \
+    CMD [ "/bin/wget", "--quiet", "--spider", "http://127.0.0.1:8000/healthz" ]
+ENTRYPOINT [ "/app/enlightenkitsap" ]
+`
+
+// addDockerfile writes a Dockerfile for running the server in a container.
+func (s *Site) addDockerfile() error {
+	return s.addRepoFile(path.Join("deploy", "Dockerfile"), []byte(dockerfile))
+}
+
+// githubActionsWorkflow generates the site when the main branch is pushed and deploys it to GitHub Pages.
+const githubActionsWorkflow = `name: Deploy Site
+
+on: push
+
+permissions:
+  contents: read
+  pages: write
+  id-token: write
+
+jobs:
+
+  build:
+    if: github.ref == 'refs/heads/main'
+    runs-on: ubuntu-latest
+    steps:
+    - uses: actions/checkout@v4
+    - uses: actions/setup-go@v5
+      with:
+        go-version-file: go.mod
+    - name: Generate the site
+      run: go generate
+    - name: Test
+      run: go test ./...
+    - uses: actions/upload-pages-artifact@v3
+      with:
+        path: build/site/
+
+  deploy:
+    needs: build
+    runs-on: ubuntu-latest
+    environment:
+      name: github-pages
+      url: ${{ steps.deployment.outputs.page_url }}
+    steps:
+    - id: deployment
+      uses: actions/deploy-pages@v4
+`
+
+// addGitHubActionsWorkflow writes a GitHub Actions workflow that deploys the site to GitHub Pages.
+// The workflow is written to the repository because GitHub only reads workflows from there.
+func (s *Site) addGitHubActionsWorkflow() error {
+	return s.addRepoFile(path.Join(".github", "workflows", "deploy.yml"), []byte(githubActionsWorkflow))
+}
+
+// addCloudflareHeaders writes Cloudflare Pages header rules that behave like the server.
+// Cloudflare combines the values of headers from every matching rule, so the static extensions are listed instead of using a splat for caching.
+func (s *Site) addCloudflareHeaders() error {
+	var sb strings.Builder
+	rule := func(pattern string, hs ...headers.Header) {
+		sb.WriteString(pattern + "\n")
+		for _, h := range hs {
+			fmt.Fprintf(&sb, "  %v: %v\n", h.Key, h.Value)
+		}
+	}
+	rule("/*", s.securityHeaders()...)
+	htmlCacheControl := headers.Header{Key: "Cache-Control", Value: headers.MaxAge(headers.HTMLMaxAge)}
+	rule("/", htmlCacheControl)
+	rule("/*.html", htmlCacheControl)
+	staticCacheControl := headers.Header{Key: "Cache-Control", Value: headers.MaxAge(headers.StaticMaxAge)}
+	for _, ext := range headers.StaticExts {
+		rule("/*"+ext, staticCacheControl)
+	}
+	return s.addDeployFile("_headers", []byte(sb.String()))
+}
+
+type (
+	vercelConfig struct {
+		Rewrites  []vercelRoute   `json:"rewrites"`
+		Redirects []vercelRoute   `json:"redirects"`
+		Headers   []vercelHeaders `json:"headers"`
+	}
+	vercelRoute struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Permanent   bool   `json:"permanent,omitempty"`
+	}
+	vercelHeaders struct {
+		Source  string         `json:"source"`
+		Headers []vercelHeader `json:"headers"`
+	}
+	vercelHeader struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+)
+
+// addVercelConfig writes Vercel rules that behave like the server.
+// Later header rules override earlier ones, so html caching is last.
+func (s *Site) addVercelConfig() error {
+	cfg := vercelConfig{
+		Rewrites:  []vercelRoute{},
+		Redirects: []vercelRoute{},
+		Headers: []vercelHeaders{
+			{"/(.*)", []vercelHeader{{"Cache-Control", headers.MaxAge(headers.StaticMaxAge)}}},
+			{"/", []vercelHeader{{"Cache-Control", headers.MaxAge(headers.HTMLMaxAge)}}},
+			{"/(.*).html", []vercelHeader{{"Cache-Control", headers.MaxAge(headers.HTMLMaxAge)}}},
+		},
+	}
+	for _, h := range s.securityHeaders() {
+		all := &cfg.Headers[0].Headers
+		*all = append(*all, vercelHeader(h))
+	}
+	for _, r := range s.redirectRules() {
+		route := vercelRoute{
+			Source:      r.From,
+			Destination: r.To,
+		}
+		switch r.Code {
+		case http.StatusOK:
+			cfg.Rewrites = append(cfg.Rewrites, route)
+		default:
+			route.Permanent = r.Code == http.StatusMovedPermanently
+			cfg.Redirects = append(cfg.Redirects, route)
+		}
+	}
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	return s.addDeployFile("vercel.json", data)
+}
+
+type (
+	lighthouseConfig struct {
+		CI struct {
+			Collect struct {
+				StaticDistDir string `json:"staticDistDir"`
+			} `json:"collect"`
+			Assert struct {
+				Assertions map[string][]any `json:"assertions"`
+			} `json:"assert"`
+		} `json:"ci"`
+	}
+	lighthouseMinScore struct {
+		MinScore float64 `json:"minScore"`
+	}
+)
+
+// lighthouseCategories are the audit categories that are asserted, even without a configured threshold.
+var lighthouseCategories = []string{"performance", "accessibility", "best-practices", "seo"}
+
+const defaultLighthouseThreshold = 0.9
+
+// addLighthouseConfig writes a Lighthouse CI configuration that fails when a category scores below its threshold.
+func (s *Site) addLighthouseConfig() error {
+	thresholds := make(map[string]float64, len(lighthouseCategories))
+	for _, c := range lighthouseCategories {
+		thresholds[c] = defaultLighthouseThreshold
+	}
+	for c, t := range s.LighthouseThresholds {
+		if t < 0 || t > 1 {
+			return fmt.Errorf("lighthouse threshold for %v must be between 0 and 1, got %v", c, t)
+		}
+		thresholds[c] = t
+	}
+	var cfg lighthouseConfig
+	cfg.CI.Collect.StaticDistDir = "."
+	cfg.CI.Assert.Assertions = make(map[string][]any, len(thresholds))
+	for c, t := range thresholds {
+		cfg.CI.Assert.Assertions["categories:"+c] = []any{"error", lighthouseMinScore{t}}
+	}
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	return s.addDeployFile(".lighthouserc.json", data)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"enlightenkitsap.org/internal/headers"
+)
+
+func TestAddHtaccess(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateHtaccess = true
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/.htaccess"])
+	wantDirectives := []string{
+		"RewriteRule ^$ /home.html",
+		"AddOutputFilterByType DEFLATE text/html",
+		`Header set Cache-Control "max-age=86400"`,
+		`Header set Cache-Control "max-age=31536000"`,
+	}
+	for _, want := range wantDirectives {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted .htaccess to contain %q, got:\n%v", want, got)
+		}
+	}
+}
+
+func TestAddNetlifyRedirects(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateNetlifyRedirects = true
+	s.RedirectMap = map[string]string{
+		"/old.html":    "/new.html",
+		"/events.html": "/future-events.html",
+	}
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/_redirects"])
+	want := "/ /home.html 200\n" +
+		"/events.html /future-events.html 301\n" +
+		"/old.html /new.html 301\n"
+	if want != got {
+		t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestAddVercelConfig(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateVercelConfig = true
+	s.ContentSecurityPolicy = "default-src 'self'"
+	s.RedirectMap = map[string]string{"/old.html": "/new.html"}
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got vercelConfig
+	if err := json.Unmarshal(s.files["dest/vercel.json"], &got); err != nil {
+		t.Fatalf("parsing vercel.json: %v", err)
+	}
+	wantRewrites := []vercelRoute{{Source: "/", Destination: "/home.html"}}
+	if want, got := fmt.Sprint(wantRewrites), fmt.Sprint(got.Rewrites); want != got {
+		t.Errorf("rewrites not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	wantRedirects := []vercelRoute{{Source: "/old.html", Destination: "/new.html", Permanent: true}}
+	if want, got := fmt.Sprint(wantRedirects), fmt.Sprint(got.Redirects); want != got {
+		t.Errorf("redirects not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	wantHeaders := []vercelHeader{
+		{"Content-Security-Policy", s.ContentSecurityPolicy},
+		{"X-Content-Type-Options", "nosniff"},
+	}
+	for _, wantHeader := range wantHeaders {
+		if !slices.Contains(got.Headers[0].Headers, wantHeader) {
+			t.Errorf("wanted first header rule to contain %v, got %v", wantHeader, got.Headers[0])
+		}
+	}
+}
+
+func TestAddLighthouseConfig(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateLighthouseConfig = true
+	s.LighthouseThresholds = map[string]float64{"seo": 0.95}
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got struct {
+		CI struct {
+			Assert struct {
+				Assertions map[string][]json.RawMessage `json:"assertions"`
+			} `json:"assert"`
+		} `json:"ci"`
+	}
+	if err := json.Unmarshal(s.files["dest/.lighthouserc.json"], &got); err != nil {
+		t.Fatalf("parsing .lighthouserc.json: %v", err)
+	}
+	want := map[string]string{
+		"categories:performance":    `["error",{"minScore":0.9}]`,
+		"categories:accessibility":  `["error",{"minScore":0.9}]`,
+		"categories:best-practices": `["error",{"minScore":0.9}]`,
+		"categories:seo":            `["error",{"minScore":0.95}]`,
+	}
+	if want, got := len(want), len(got.CI.Assert.Assertions); want != got {
+		t.Errorf("wanted %v assertions, got %v", want, got)
+	}
+	for k, v := range want {
+		a, ok := got.CI.Assert.Assertions[k]
+		if !ok {
+			t.Errorf("missing assertion for %v", k)
+			continue
+		}
+		b, err := json.Marshal(a)
+		if err != nil {
+			t.Fatalf("marshalling assertion: %v", err)
+		}
+		if got := string(b); v != got {
+			t.Errorf("assertion for %v: wanted %v, got %v", k, v, got)
+		}
+	}
+}
+
+func TestAddLighthouseConfigBadThreshold(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateLighthouseConfig = true
+	s.LighthouseThresholds = map[string]float64{"performance": 90}
+	if err := s.addDeployFiles(); err == nil {
+		t.Errorf("wanted error for threshold out of range")
+	}
+}
+
+func TestAddCloudflareHeaders(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateCloudflareHeaders = true
+	s.ContentSecurityPolicy = "default-src 'self'; frame-src https://www.youtube.com"
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/_headers"])
+	wantParts := []string{
+		"/*\n  X-Frame-Options: SAMEORIGIN\n  X-Content-Type-Options: nosniff\n  Referrer-Policy: strict-origin-when-cross-origin\n  Content-Security-Policy: default-src 'self';",
+		"/*.webp\n  Cache-Control: max-age=31536000\n",
+		"/*.js\n  Cache-Control: max-age=31536000\n",
+		"/*.html\n  Cache-Control: max-age=86400\n",
+		"/*.jpg\n  Cache-Control: max-age=31536000\n",
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted _headers to contain %q, got:\n%v", want, got)
+		}
+	}
+}
+
+func TestAddCloudflareHeadersNoCSP(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateCloudflareHeaders = true
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/_headers"])
+	if strings.Contains(got, "Content-Security-Policy") {
+		t.Errorf("wanted no Content-Security-Policy without a policy, got:\n%v", got)
+	}
+	if want := "/*\n  X-Frame-Options: SAMEORIGIN\n"; !strings.Contains(got, want) {
+		t.Errorf("wanted _headers to contain %q, got:\n%v", want, got)
+	}
+}
+
+func TestStaticExtsCoverSite(t *testing.T) {
+	cfg := Config{
+		Dest:                   "dest",
+		Concurrency:            1,
+		GenerateBrowserConfig:  true,
+		GenerateEmailPages:     true,
+		ServePageAPI:           true,
+		SecurityContact:        "security@example.com",
+		GenerateDarkMode:       true,
+		GenerateNextJSMetadata: true,
+		PlainTextEvents:        true,
+		SearchCorpus:           true,
+		EnableJS:               true,
+		GenerateGallery:        true,
+		GenerateAppShell:       true,
+		GenerateVolunteersJSON: true,
+		FingerprintAssets:      true,
+	}
+	s := newSite(cfg)
+	files := make(map[string][]byte)
+	s.removeAll = func(path string) error { return nil }
+	s.mkdirAll = func(path string) error { return nil }
+	s.writeFile = func(name string, data []byte) error {
+		files[name] = data
+		return nil
+	}
+	if err := s.writeSite(); err != nil {
+		t.Fatalf("writing site: %v", err)
+	}
+	for name := range files {
+		ext := path.Ext(name)
+		if !headers.IsPage(ext) && !slices.Contains(headers.StaticExts, ext) {
+			t.Errorf("the extension of %v is not a page or a static extension, so other hosts will not cache it like the server", name)
+		}
+	}
+}
+
+func TestAddDockerfile(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateDockerfile = true
+	s.RepoDir = "repo"
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["repo/deploy/Dockerfile"]
+	if !ok {
+		t.Fatalf("Dockerfile not written to repository: %v", s.files)
+	}
+	if _, ok := s.files["dest/deploy/Dockerfile"]; ok {
+		t.Errorf("wanted Dockerfile to not be written with the site, where it would be served")
+	}
+	for _, want := range []string{"\nFROM ", "\nCOPY ", "\nEXPOSE 8000\n", "\nHEALTHCHECK ", "/healthz"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("wanted Dockerfile to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestAddGitHubActionsWorkflow(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateGHAWorkflow = true
+	s.RepoDir = "repo"
+	if err := s.addDeployFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["repo/.github/workflows/deploy.yml"]
+	if !ok {
+		t.Fatalf("workflow not written to repository: %v", s.files)
+	}
+	if _, ok := s.files["dest/.github/workflows/deploy.yml"]; ok {
+		t.Errorf("wanted workflow to not be written with the site, where GitHub does not read it")
+	}
+	for _, want := range []string{"on: push", "go generate", "actions/upload-pages-artifact"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("wanted workflow to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestCompressWrites(t *testing.T) {
+	s := newTestSite(nil)
+	s.compressWrites()
+	files := map[string]string{
+		"dest/home.html":  "<p>home</p>",
+		"dest/bundle.css": "body {}",
+		"dest/init.js":    "init()",
+		"dest/rss.xml":    "<rss></rss>",
+	}
+	for name, data := range files {
+		if err := s.writeFile(name, []byte(data)); err != nil {
+			t.Fatalf("writing %v: %v", name, err)
+		}
+	}
+	for name, want := range files {
+		if got := string(s.files[name]); want != got {
+			t.Errorf("%v: not equal: wanted %q, got %q", name, want, got)
+		}
+		gz, ok := s.files[name+".gz"]
+		if path.Ext(name) == ".xml" {
+			if ok {
+				t.Errorf("did not want compressed copy of %v", name)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("wanted compressed copy of %v", name)
+			continue
+		}
+		gr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			t.Fatalf("creating gzip reader: %v", err)
+		}
+		got, err := io.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("reading compressed %v: %v", name, err)
+		}
+		if want != string(got) {
+			t.Errorf("%v: decompressed not equal: wanted %q, got %q", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var rootLinkRE = regexp.MustCompile(`\b(href|src)="(/|/[^/"][^"]*)"`)
+
+// addEmailPage writes a version of the page in the email folder that can be pasted into an email.
+// The page does not have the navigation menu and links to the rest of the site are absolute.
+func (s *Site) addEmailPage(pageName, srcDir, srcFile string, data interface{}) error {
+	return s.addEmailPageAs(pageName, srcDir, srcFile, srcFile, data)
+}
+
+func (s *Site) addEmailPageAs(pageName, srcDir, srcFile, destName string, data interface{}) error {
+	patterns := []string{
+		path.Join(resources, "email-main.html"),
+		path.Join(resources, srcDir, srcFile),
+	}
+	t := s.newTemplate("email-main.html")
+	if _, err := t.ParseFS(s.fSys, patterns...); err != nil {
+		return fmt.Errorf("parsing email template filesystem: %w", err)
+	}
+	tmplData := Data{
+		Site: *s,
+		Page: Page{
+			Name: pageName,
+			Data: data,
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := s.executeTemplate(buf, t, tmplData); err != nil {
+		return fmt.Errorf("executing email template: %w", err)
+	}
+	baseURL := strings.TrimSuffix(s.absURL("/"), "/")
+	b := rootLinkRE.ReplaceAll(buf.Bytes(), []byte(`$1="`+baseURL+`$2"`))
+	dest := path.Join(s.dest, "email", destName)
+	if err := s.checkFilenameLen(dest); err != nil {
+		return err
+	}
+	if err := s.mkdirAll(path.Dir(dest)); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	if err := s.writeFile(dest, b); err != nil {
+		return fmt.Errorf("writing email page: %w", err)
+	}
+	return nil
+}
+
+// reminderDateLayout is how the date of an event is written in reminder emails.
+const reminderDateLayout = "Monday, January 2, 2006"
+
+type reminder struct {
+	Title    string
+	Date     string
+	Location string
+	RSVPHref string
+	Content  string
+}
+
+// addReminderEmailTemplates writes reminders for the future events that have a start date in their frontmatter.
+// Events without a start date or that have already happened are skipped.
+func (s *Site) addReminderEmailTemplates(eg *EventGroup) error {
+	today := s.now().Truncate(24 * time.Hour)
+	var dates []time.Time
+	for _, e := range eg.Entries {
+		d := e.StartDate
+		if d.IsZero() || d.Before(today) || slices.ContainsFunc(dates, func(d2 time.Time) bool { return sameDay(d, d2) }) {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	for _, d := range dates {
+		if err := s.addReminderEmailTemplate(eg, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addReminderEmailTemplate writes a reminder email for each event of the group that starts on the day of the date.
+func (s *Site) addReminderEmailTemplate(eg *EventGroup, eventDate time.Time) error {
+	for _, e := range eg.Entries {
+		if e.StartDate.IsZero() || !sameDay(e.StartDate, eventDate) {
+			continue
+		}
+		r := reminder{
+			Title:    e.Title,
+			Date:     eventDate.Format(reminderDateLayout),
+			Location: s.EventLocation,
+			RSVPHref: "/sign-up.html",
+			Content:  e.Content,
+		}
+		destName := path.Join("reminders", slug(e.Title)+".html")
+		if err := s.addEmailPageAs("Reminder: "+e.Title, events, "reminder.html", destName, r); err != nil {
+			return fmt.Errorf("adding reminder for %v: %w", e.File, err)
+		}
+	}
+	return nil
+}
+
+// sameDay reports whether the times are on the same calendar day.
+func sameDay(a, b time.Time) bool {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
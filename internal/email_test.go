@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAddEmailPage(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/email-main.html"] = &fstest.MapFile{Data: []byte(`<table><tr><td><a href="/">{{.Site.Name}}</a>{{template "content" .Page.Data}}</td></tr></table>`)}
+	fSys["resources/events/future-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}<a href="/sign-up.html">sign up</a>{{.Events.String}}{{end}}`)}
+	fSys["resources/events/future/001_a.html"] = testEvent(`<img src="/images/a.jpg"><a href="https://zoom.us">zoom</a>`, "")
+	fSys["resources/events/reminder.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Date}}{{end}}`)}
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com/"
+	s.GenerateEmailPages = true
+	if err := s.addFutureEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["dest/email/future-events.html"]
+	if !ok {
+		t.Fatalf("email page not written: %v", s.files)
+	}
+	wantParts := []string{
+		`<a href="https://example.com/">TestSite</a>`,
+		`<a href="https://example.com/sign-up.html">sign up</a>`,
+		`<img src="https://example.com/images/a.jpg">`,
+		`<a href="https://zoom.us">zoom</a>`,
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("wanted email page to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(string(got), "<nav") {
+		t.Errorf("did not want navigation on email page, got:\n%s", got)
+	}
+}
+
+func TestAddReminderEmailTemplate(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/email-main.html"] = &fstest.MapFile{Data: []byte(`<h2>{{.Page.Name}}</h2>{{template "content" .Page.Data}}`)}
+	fSys["resources/events/reminder.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Title}} on {{.Date}} at {{.Location}}: <a href="{{.RSVPHref}}">RSVP</a>{{.Content}}{{end}}`)}
+	fSys["resources/events/future/007_jane_doe.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"startDate": "2023-07-21"} */}}` +
+		`{{define "event"}}birds{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/future/009_bird_walk.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"name": "Bird Walk", "startDate": "2023-09-15"} */}}` +
+		`{{define "event"}}walk{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/future/010_john_doe.html"] = testEvent("no date yet", "")
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com"
+	s.EventLocation = "the park"
+	s.GenerateEmailPages = true
+	if err := s.addFutureEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{
+			"dest/email/reminders/bird-walk.html",
+			`<h2>Reminder: Bird Walk</h2>Bird Walk on Friday, September 15, 2023 at the park: <a href="https://example.com/sign-up.html">RSVP</a>walk`,
+		},
+	}
+	for _, test := range tests {
+		got, ok := s.files[test.file]
+		if !ok {
+			t.Errorf("reminder not written: %v", test.file)
+			continue
+		}
+		if want := test.want; want != string(got) {
+			t.Errorf("%v not equal:\nwanted: %q\ngot:    %q", test.file, want, got)
+		}
+	}
+	for _, file := range []string{
+		"dest/email/reminders/jane-doe.html", // already happened
+		"dest/email/reminders/john-doe.html", // no start date
+	} {
+		if _, ok := s.files[file]; ok {
+			t.Errorf("did not want reminder: %v", file)
+		}
+	}
+}
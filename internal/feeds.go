@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type (
+	rss struct {
+		XMLName xml.Name   `xml:"rss"`
+		Version string     `xml:"version,attr"`
+		Channel rssChannel `xml:"channel"`
+	}
+	rssChannel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	}
+	rssItem struct {
+		Title       string        `xml:"title"`
+		Link        string        `xml:"link"`
+		Description string        `xml:"description,omitempty"`
+		PubDate     string        `xml:"pubDate,omitempty"`
+		Enclosure   *rssEnclosure `xml:"enclosure"`
+	}
+	rssEnclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	}
+	atomFeed struct {
+		XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+		Title   string      `xml:"title"`
+		ID      string      `xml:"id"`
+		Links   []atomLink  `xml:"link"`
+		Updated string      `xml:"updated"`
+		Author  atomAuthor  `xml:"author"`
+		Entries []atomEntry `xml:"entry"`
+	}
+	atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr,omitempty"`
+	}
+	atomAuthor struct {
+		Name string `xml:"name"`
+	}
+	atomEntry struct {
+		Title   string      `xml:"title"`
+		ID      string      `xml:"id"`
+		Link    atomLink    `xml:"link"`
+		Updated string      `xml:"updated"`
+		Content atomContent `xml:"content"`
+	}
+	atomContent struct {
+		Type string `xml:"type,attr"`
+		Body string `xml:",chardata"`
+	}
+	// ManifestDiff is a page that was added or changed between two builds.
+	ManifestDiff struct {
+		Path   string
+		Title  string
+		Status string
+		Date   time.Time
+	}
+)
+
+// absURL creates a link to the path on the site.
+func (s *Site) absURL(p string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + s.urlPath(p)
+}
+
+// urlPath is the path of the file under the base path of the site, such as /enlighten/home.html for home.html.
+func (s *Site) urlPath(p string) string {
+	u := path.Join("/", s.BasePath, p)
+	if strings.HasSuffix(p, "/") && u != "/" {
+		u += "/"
+	}
+	return u
+}
+
+// addChangelogFeed writes a feed of the pages that have changed.
+func (s *Site) addChangelogFeed(changes []ManifestDiff) error {
+	items := make([]rssItem, len(changes))
+	for i, c := range changes {
+		items[i] = rssItem{
+			Title:       c.Title,
+			Link:        s.absURL(c.Path),
+			Description: c.Status,
+			PubDate:     c.Date.Format(time.RFC1123Z),
+		}
+	}
+	c := rssChannel{
+		Title:       s.Name + " changes",
+		Link:        s.absURL("/"),
+		Description: "Updates to the " + s.Name + " site",
+		Items:       items,
+	}
+	if err := s.addRSS("changelog.rss", c); err != nil {
+		return fmt.Errorf("writing changelog feed: %w", err)
+	}
+	return nil
+}
+
+func (s *Site) addRSS(destName string, c rssChannel) error {
+	feed := rss{
+		Version: "2.0",
+		Channel: c,
+	}
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating rss: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	dest := path.Join(s.dest, destName)
+	if err := s.mkdirAll(path.Dir(dest)); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing rss: %w", err)
+	}
+	return nil
+}
+
+// addResourceFeed writes a feed of the downloadable resources of past events, most recently modified first.
+func (s *Site) addResourceFeed(yrs []EventGroup) error {
+	var files []ResourceFile
+	for _, eg := range yrs {
+		files = append(files, eg.Files...)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	items := make([]rssItem, len(files))
+	for i, f := range files {
+		link := s.absURL(f.Path)
+		items[i] = rssItem{
+			Title: path.Base(f.Path),
+			Link:  link,
+			Enclosure: &rssEnclosure{
+				URL:    link,
+				Length: f.Size,
+				Type:   mimeTypeFor(path.Ext(f.Path)),
+			},
+		}
+		if !f.ModTime.IsZero() {
+			items[i].PubDate = f.ModTime.Format(time.RFC1123Z)
+		}
+	}
+	c := rssChannel{
+		Title:       s.Name + " resources",
+		Link:        s.absURL("/past-events.html"),
+		Description: "Documents from past " + s.Name + " events",
+		Items:       items,
+	}
+	if err := s.addRSS(path.Join("resources", "feed.rss"), c); err != nil {
+		return fmt.Errorf("writing resource feed: %w", err)
+	}
+	return nil
+}
+
+// addFeed writes an Atom feed of the future and past events.
+// Events without dates are considered updated when the site is built.
+func (s *Site) addFeed() error {
+	updated := s.now().UTC().Format(time.RFC3339)
+	author := s.FeedAuthor
+	if len(author) == 0 {
+		author = s.Name
+	}
+	feedPath := path.Join("/", events, "feed.atom")
+	feed := atomFeed{
+		Title: s.Name + " events",
+		ID:    s.absURL(feedPath),
+		Links: []atomLink{
+			{Href: s.absURL(feedPath), Rel: "self"},
+			{Href: s.absURL("/")},
+		},
+		Updated: updated,
+		Author:  atomAuthor{author},
+	}
+	var groups []EventGroup
+	if s.futureEvents != nil {
+		groups = append(groups, *s.futureEvents)
+	}
+	groups = append(groups, s.pastEvents...)
+	for _, eg := range groups {
+		link := s.absURL(s.pastEventsYearPath(eg.Year))
+		if eg.Year == "future" {
+			link = s.absURL("/future-events.html")
+		}
+		for _, e := range eg.Entries {
+			entryUpdated := updated
+			if !e.Date.IsZero() {
+				entryUpdated = e.Date.Format(time.RFC3339)
+			}
+			entry := atomEntry{
+				Title:   e.Title,
+				ID:      s.absURL(path.Join(events, eg.Year, e.File)),
+				Link:    atomLink{Href: link},
+				Updated: entryUpdated,
+				Content: atomContent{"html", e.Content},
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+	}
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating atom feed: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	dest := path.Join(s.dest, feedPath)
+	if err := s.mkdirAll(path.Dir(dest)); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing atom feed: %w", err)
+	}
+	return nil
+}
+
+// slug creates a lowercase, hyphenated name that is safe for urls.
+func slug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// speakerEvents groups the events of each year by the speaker.
+func speakerEvents(yrs []EventGroup) map[string][]EventGroup {
+	m := make(map[string][]EventGroup)
+	for _, yr := range yrs {
+		for _, e := range yr.Entries {
+			groups := m[e.Speaker]
+			if n := len(groups); n == 0 || groups[n-1].Year != yr.Year {
+				groups = append(groups, EventGroup{Year: yr.Year})
+			}
+			last := &groups[len(groups)-1]
+			last.Entries = append(last.Entries, e)
+			m[e.Speaker] = groups
+		}
+	}
+	return m
+}
+
+// addSpeakerFeeds writes a feed of the events of each speaker.
+func (s *Site) addSpeakerFeeds(speakerEvents map[string][]EventGroup) error {
+	for speaker, groups := range speakerEvents {
+		var items []rssItem
+		for _, eg := range groups {
+			for _, e := range eg.Entries {
+				item := rssItem{
+					Title: e.Title,
+					Link:  s.absURL(s.pastEventsYearPath(eg.Year)),
+				}
+				if len(e.ResourcesHref) != 0 {
+					item.Link = s.absURL(e.ResourcesHref)
+				}
+				if !e.Date.IsZero() {
+					item.PubDate = e.Date.Format(time.RFC1123Z)
+				}
+				items = append(items, item)
+			}
+		}
+		c := rssChannel{
+			Title:       speaker + " at " + s.Name,
+			Link:        s.absURL("past-events.html"),
+			Description: "Events with " + speaker,
+			Items:       items,
+		}
+		destName := path.Join("feeds", "speakers", slug(speaker)+".rss")
+		if err := s.addRSS(destName, c); err != nil {
+			return fmt.Errorf("writing feed for %v: %w", speaker, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestAddChangelogFeed(t *testing.T) {
+	s := newTestSite(nil)
+	s.BaseURL = "https://example.com/"
+	date := time.Date(2023, 10, 20, 0, 0, 0, 0, time.UTC)
+	changes := []ManifestDiff{
+		{Path: "home.html", Title: "Home Page", Status: "changed", Date: date},
+		{Path: "resources/events/2023/001_a.html", Title: "A", Status: "added", Date: date},
+	}
+	if err := s.addChangelogFeed(changes); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/changelog.rss"])
+	if want, got := 2, strings.Count(got, "<item>"); want != got {
+		t.Errorf("wanted %v items, got %v", want, got)
+	}
+	wantLinks := []string{
+		"<link>https://example.com/home.html</link>",
+		"<link>https://example.com/resources/events/2023/001_a.html</link>",
+	}
+	for _, want := range wantLinks {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted feed to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Jane Doe", "jane-doe"},
+		{"Dr. Po & Christine Karczewski", "dr-po-christine-karczewski"},
+		{"  ", ""},
+	}
+	for _, test := range tests {
+		if want, got := test.want, slug(test.name); want != got {
+			t.Errorf("slug of %q: wanted %q, got %q", test.name, want, got)
+		}
+	}
+}
+
+func TestAddSpeakerFeeds(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2022/003_jane_doe.html"] = testEvent("birds", "video")
+	fSys["resources/events/past/2023/2023-04-21_bird_walk.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"name": "Bird Walk", "speaker": "Jane Doe"} */}}` +
+		`{{define "event"}}walk{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/past/2023/005_john_smith.html"] = testEvent("energy", "")
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com"
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["dest/feeds/speakers/jane-doe.rss"]
+	if !ok {
+		t.Fatalf("speaker feed not written: %v", s.files)
+	}
+	if want, got := 2, strings.Count(string(got), "<item>"); want != got {
+		t.Errorf("wanted %v items, got %v", want, got)
+	}
+	wantParts := []string{
+		"<title>Bird Walk</title>",
+		"<link>https://example.com/past-events.html#year-2023</link>",
+		"<pubDate>Fri, 21 Apr 2023 00:00:00 +0000</pubDate>",
+		"<title>Jane Doe</title>",
+		"<link>https://example.com/resources/events/2022/003_jane_doe.html</link>",
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("wanted feed to contain %q, got:\n%s", want, got)
+		}
+	}
+	if _, ok := s.files["dest/feeds/speakers/john-smith.rss"]; !ok {
+		t.Errorf("feed for other speaker not written")
+	}
+}
+
+func TestAddResourceFeed(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2022/003_birds.html"] = testEvent("birds", `<a href="/resources/events/2022/003_birds.pdf">slides</a>`)
+	fSys["resources/events/past/2022/003_birds.pdf"] = &fstest.MapFile{Data: []byte("pdf"), ModTime: time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC)}
+	fSys["resources/events/past/2023/004_budget.html"] = testEvent("budget", `<a href="/resources/events/2023/004_budget.xlsx">budget</a>`)
+	fSys["resources/events/past/2023/004_budget.xlsx"] = &fstest.MapFile{Data: []byte("xlsx"), ModTime: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)}
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com"
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["dest/resources/feed.rss"]
+	if !ok {
+		t.Fatalf("resource feed not written: %v", s.files)
+	}
+	wantItems := []string{
+		"<title>004_budget.xlsx</title>\n" +
+			"\t\t\t<link>https://example.com/resources/events/2023/004_budget.xlsx</link>\n" +
+			"\t\t\t<pubDate>Sat, 01 Apr 2023 00:00:00 +0000</pubDate>\n" +
+			"\t\t\t<enclosure url=\"https://example.com/resources/events/2023/004_budget.xlsx\" length=\"4\" type=\"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet\"></enclosure>",
+		"<title>003_birds.pdf</title>\n" +
+			"\t\t\t<link>https://example.com/resources/events/2022/003_birds.pdf</link>\n" +
+			"\t\t\t<pubDate>Sat, 05 Mar 2022 00:00:00 +0000</pubDate>\n" +
+			"\t\t\t<enclosure url=\"https://example.com/resources/events/2022/003_birds.pdf\" length=\"3\" type=\"application/pdf\"></enclosure>",
+	}
+	prev := -1
+	for _, want := range wantItems {
+		i := strings.Index(string(got), want)
+		switch {
+		case i < 0:
+			t.Errorf("wanted feed to contain %q, got:\n%s", want, got)
+		case i < prev:
+			t.Errorf("wanted newer resources first, got:\n%s", got)
+		}
+		prev = i
+	}
+}
+
+func TestAddFeed(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/future/010_josh_farley.html"] = testEvent("<p>economics</p>", "")
+	fSys["resources/events/past/2023/2023-04-21_bird_walk.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"name": "Bird Walk"} */}}` +
+		`{{define "event"}}<p>walk</p>{{end}}{{define "resources"}}{{end}}`)}
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com"
+	s.FeedAuthor = "Jane Doe"
+	if err := s.addEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["dest/events/feed.atom"]
+	if !ok {
+		t.Fatalf("feed not written: %v", s.files)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(got, &feed); err != nil {
+		t.Fatalf("parsing feed: %v", err)
+	}
+	if want, got := 2, len(feed.Entries); want != got {
+		t.Fatalf("wanted %v entries, got %v", want, got)
+	}
+	wantParts := []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		"<name>Jane Doe</name>",
+		"<updated>2023-08-01T12:00:00Z</updated>",
+		"<title>Josh Farley</title>",
+		`<link href="https://example.com/future-events.html"></link>`,
+		"<title>Bird Walk</title>",
+		`<link href="https://example.com/past-events.html#year-2023"></link>`,
+		"<updated>2023-04-21T00:00:00Z</updated>",
+		`<content type="html">&lt;p&gt;walk&lt;/p&gt;</content>`,
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("wanted feed to contain %q, got:\n%s", want, got)
+		}
+	}
+}
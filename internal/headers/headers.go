@@ -0,0 +1,48 @@
+// Package headers lists the response headers of the server so the site generator can write the same headers for other hosts.
+package headers
+
+import (
+	"strconv"
+	"time"
+)
+
+// Header is the name and value of a response header.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Security are the headers that the server sets on every response, other than the configurable Content-Security-Policy.
+var Security = []Header{
+	{"X-Frame-Options", "SAMEORIGIN"},
+	{"X-Content-Type-Options", "nosniff"},
+	{"Referrer-Policy", "strict-origin-when-cross-origin"},
+}
+
+const (
+	// HTMLMaxAge is how long pages are cached.
+	HTMLMaxAge = 24 * time.Hour
+	// StaticMaxAge is how long the other files of the site are cached.
+	StaticMaxAge = 365 * HTMLMaxAge
+)
+
+// StaticExts are the extensions of the files that the site generator writes that are not pages.
+// Hosts that cannot set a header for every file that is not a page, such as Cloudflare, list these extensions instead.
+var StaticExts = []string{
+	".jpg", ".webp", ".gif", ".png", ".svg",
+	".pdf", ".docx", ".xlsx",
+	".mp3", ".m4a", ".wav",
+	".css", ".js",
+	".json", ".xml", ".rss", ".atom", ".ics", ".txt", ".vtt",
+}
+
+// IsPage reports whether files with the extension are pages, which are cached for HTMLMaxAge.
+// Paths without extensions are directories, such as the home page.
+func IsPage(ext string) bool {
+	return ext == ".html" || len(ext) == 0
+}
+
+// MaxAge is the Cache-Control value for the duration.
+func MaxAge(d time.Duration) string {
+	return "max-age=" + strconv.Itoa(int(d.Seconds()))
+}
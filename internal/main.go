@@ -1,10 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 //go:embed resources
@@ -17,7 +33,9 @@ const (
 	perm      = 0764
 	kiloByte  = 1_000 * 1
 	megaByte  = 1_000 * kiloByte
+	kB10      = 10 * kiloByte
 	kB50      = 50 * kiloByte
+	mB5       = 5 * megaByte
 	mB10      = 10 * megaByte
 )
 
@@ -29,18 +47,207 @@ func usage() {
 }
 
 type Config struct {
-	Dest        string
-	OneResource bool
+	Dest                      string
+	OneResource               bool
+	StrictPageNames           bool
+	CompressPDFs              bool
+	RequireNonEmptyDirs       bool
+	MaxFutureEvents           int
+	MaxFilenameLen            int
+	BaseURL                   string
+	GenerateHtaccess          bool
+	GenerateNetlifyRedirects  bool
+	RedirectMap               map[string]string
+	GenerateVercelConfig      bool
+	BundleCSS                 bool
+	ThemeColor                string
+	GenerateBrowserConfig     bool
+	ContributorNames          []string
+	Microformats              bool
+	DeprecatedTemplateBlocks  []string
+	GenerateEmailPages        bool
+	ServePageAPI              bool
+	SecurityContact           string
+	SecurityEncryption        string
+	GenerateDarkMode          bool
+	ThemeColors               []string
+	PreloadResources          []PreloadResource
+	GenerateLighthouseConfig  bool
+	LighthouseThresholds      map[string]float64
+	GenerateCloudflareHeaders bool
+	GenerateNextJSMetadata    bool
+	PlainTextEvents           bool
+	Languages                 []string
+	SearchCorpus              bool
+	BoardMembers              []BoardMember
+	EnableJS                  bool
+	StrictA11y                bool
+	GenerateDockerfile        bool
+	GenerateGHAWorkflow       bool
+	GenerateGallery           bool
+	Verbose                   bool
+	EventLocation             string
+	GenerateAppShell          bool
+	GenerateVolunteersJSON    bool
+	FeedAuthor                string
+	FingerprintAssets         bool
+	Concurrency               int
+	DryRun                    bool
+	Name                      string
+	Description               string
+	NoManifest                bool
+	LenientDates              bool
+	Incremental               bool
+	BuildComment              bool
+	Version                   string
+	DefaultOGImage            string
+	PastEventsPageSize        int
+	Watch                     bool
+	WatchInterval             time.Duration
+	Src                       string
+	DefaultRobotsTag          string
+	Precompress               bool
+	MaxOutputFileBytes        int
+	BasePath                  string
+	ContentSecurityPolicy     string
+	RepoDir                   string
 }
 
 // delete this section when debugging
 func main() {
 	var cfg Config
 	flag.StringVar(&cfg.Dest, "dest", "", "the location to save the site files to")
+	flag.StringVar(&cfg.RepoDir, "repo-dir", ".", "the root of the repository, where files that are not served with the site, such as deploy/Dockerfile and .github/workflows/deploy.yml, are saved")
 	flag.BoolVar(&cfg.OneResource, "one-resource", false, "show all videos and resources on one page")
+	flag.BoolVar(&cfg.StrictPageNames, "strict-page-names", false, "fail if multiple pages have the same name")
+	flag.BoolVar(&cfg.CompressPDFs, "compress-pdfs", false, "shrink event pdfs with ghostscript (gs)")
+	flag.BoolVar(&cfg.RequireNonEmptyDirs, "require-non-empty-dirs", false, "fail if an event directory has no events")
+	flag.IntVar(&cfg.MaxFutureEvents, "max-future-events", 0, "the most upcoming events to show, the most recent by date, 0 shows all")
+	flag.IntVar(&cfg.MaxFilenameLen, "max-filename-len", 0, "the longest allowed output filename in bytes, 0 allows any length")
+	flag.StringVar(&cfg.BaseURL, "base-url", "https://enlightenkitsap.org", "the url the site is hosted at")
+	flag.BoolVar(&cfg.GenerateHtaccess, "htaccess", false, "write a .htaccess file for hosting on Apache")
+	flag.BoolVar(&cfg.GenerateNetlifyRedirects, "netlify-redirects", false, "write a _redirects file for hosting on Netlify")
+	flag.Func("redirect", "a permanent redirect in the form of /old.html=/new.html, can be repeated", func(s string) error {
+		from, to, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("wanted redirect in form of from=to, got %q", s)
+		}
+		if cfg.RedirectMap == nil {
+			cfg.RedirectMap = make(map[string]string)
+		}
+		cfg.RedirectMap[from] = to
+		return nil
+	})
+	flag.BoolVar(&cfg.GenerateVercelConfig, "vercel-config", false, "write a vercel.json file for hosting on Vercel")
+	flag.BoolVar(&cfg.BundleCSS, "bundle-css", false, "combine the stylesheets in resources/css into bundle.css")
+	flag.StringVar(&cfg.ThemeColor, "theme-color", "#262626", "the main color of the site")
+	flag.BoolVar(&cfg.GenerateBrowserConfig, "browser-config", false, "write a browserconfig.xml file for Windows tiles")
+	flag.Func("contributor", "the name of a person to credit in humans.txt, can be repeated", func(s string) error {
+		cfg.ContributorNames = append(cfg.ContributorNames, s)
+		return nil
+	})
+	flag.BoolVar(&cfg.Microformats, "microformats", false, "mark up past events as a microformats h-feed")
+	flag.Func("deprecated-block", "the name of a template that event files should no longer define, can be repeated", func(s string) error {
+		cfg.DeprecatedTemplateBlocks = append(cfg.DeprecatedTemplateBlocks, s)
+		return nil
+	})
+	flag.BoolVar(&cfg.GenerateEmailPages, "email-pages", false, "write versions of the upcoming events page for emails")
+	flag.BoolVar(&cfg.ServePageAPI, "page-api", false, "write api/pages.json describing each page")
+	flag.StringVar(&cfg.SecurityContact, "security-contact", "", "the email or url to report security issues to, writes .well-known/security.txt when set")
+	flag.StringVar(&cfg.SecurityEncryption, "security-encryption", "", "the url of the key to encrypt security reports with")
+	flag.BoolVar(&cfg.GenerateDarkMode, "dark-mode", false, "write dark-mode.css, which inverts the lightness of the theme colors when users prefer dark mode")
+	flag.Func("theme-colors", "a comma-separated list of the hex colors of the site to invert for dark mode", func(s string) error {
+		cfg.ThemeColors = append(cfg.ThemeColors, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.Func("preload", "an external resource to preload as url,as[,crossorigin], can be repeated", func(s string) error {
+		parts := strings.Split(s, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("wanted url,as[,crossorigin], got %q", s)
+		}
+		r := PreloadResource{URL: parts[0], As: parts[1]}
+		if len(parts) == 3 {
+			r.CrossOrigin = parts[2]
+		}
+		cfg.PreloadResources = append(cfg.PreloadResources, r)
+		return nil
+	})
+	flag.BoolVar(&cfg.GenerateLighthouseConfig, "lighthouse-config", false, "write a .lighthouserc.json file for auditing the site with Lighthouse CI")
+	flag.Func("lighthouse-threshold", "the minimum Lighthouse score of a category in the form of seo=0.95, can be repeated", func(s string) error {
+		category, score, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("wanted threshold in form of category=score, got %q", s)
+		}
+		t, err := strconv.ParseFloat(score, 64)
+		if err != nil {
+			return fmt.Errorf("parsing lighthouse threshold: %w", err)
+		}
+		if cfg.LighthouseThresholds == nil {
+			cfg.LighthouseThresholds = make(map[string]float64)
+		}
+		cfg.LighthouseThresholds[category] = t
+		return nil
+	})
+	flag.BoolVar(&cfg.GenerateCloudflareHeaders, "cloudflare-headers", false, "write a _headers file for hosting on Cloudflare Pages")
+	flag.BoolVar(&cfg.GenerateNextJSMetadata, "nextjs-metadata", false, "write __next_export_data.json, which maps the path of each page to its metadata")
+	flag.BoolVar(&cfg.PlainTextEvents, "plain-text-events", false, "write a plain text version of each event next to its resources")
+	flag.Func("language", "the code of a language to write translations of pages for, such as es, can be repeated", func(s string) error {
+		cfg.Languages = append(cfg.Languages, s)
+		return nil
+	})
+	flag.BoolVar(&cfg.SearchCorpus, "search-corpus", false, "write search-corpus.json with the text of each page for client-side search")
+	flag.Func("board-members", "the path of a json file listing the name, role, and photoURL of each board member", func(s string) error {
+		data, err := os.ReadFile(s)
+		if err != nil {
+			return fmt.Errorf("reading board members: %w", err)
+		}
+		members, err := parseBoardMembers(data)
+		if err != nil {
+			return err
+		}
+		cfg.BoardMembers = members
+		return nil
+	})
+	flag.BoolVar(&cfg.EnableJS, "enable-js", false, "write init.js, which lazily loads images and smoothly scrolls, and add it to each page")
+	flag.BoolVar(&cfg.StrictA11y, "strict-a11y", false, "fail if a page does not have main and nav landmarks")
+	flag.BoolVar(&cfg.GenerateDockerfile, "dockerfile", false, "write deploy/Dockerfile for running the server in a container")
+	flag.BoolVar(&cfg.GenerateGHAWorkflow, "gha-workflow", false, "write .github/workflows/deploy.yml in the repository for deploying the site to GitHub Pages")
+	flag.BoolVar(&cfg.GenerateGallery, "gallery", false, "write gallery.html with the photos of past events")
+	flag.BoolVar(&cfg.Verbose, "verbose", false, "log extra warnings, such as events on the same date")
+	flag.StringVar(&cfg.EventLocation, "event-location", "St. Paul's Episcopal Church, 700 Callahan Drive, Bremerton", "where events are held, for reminder emails")
+	flag.BoolVar(&cfg.GenerateAppShell, "app-shell", false, "write app-shell.html, the layout of the site with an empty main element for service workers to cache")
+	flag.BoolVar(&cfg.GenerateVolunteersJSON, "volunteers-json", false, "write about/volunteers.json from the opportunities marked up on the volunteers page")
+	flag.StringVar(&cfg.FeedAuthor, "feed-author", "", "the author of the events feed, defaults to the name of the site")
+	flag.BoolVar(&cfg.FingerprintAssets, "fingerprint-assets", false, "add a hash of the contents of stylesheets and images to their names so they can be cached for a long time")
+	flag.IntVar(&cfg.Concurrency, "concurrency", runtime.NumCPU(), "the maximum number of past event years to add at the same time")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "list the files that would be written without changing the destination directory")
+	flag.StringVar(&cfg.Name, "name", "Enl!ghten", "the name of the organization")
+	flag.StringVar(&cfg.Description, "description", "Kitsap Community Forum", "the description of the organization, shown below its name")
+	flag.BoolVar(&cfg.NoManifest, "no-manifest", false, "do not write manifest.json, which lists the size and hash of each file in the site")
+	flag.BoolVar(&cfg.LenientDates, "lenient-dates", false, "warn instead of failing when an event file name does not start with a date or an order number")
+	flag.BoolVar(&cfg.Incremental, "incremental", false, "keep the destination directory, only writing files that changed; files that are no longer generated are not removed")
+	flag.BoolVar(&cfg.BuildComment, "build-comment", false, "add a comment with the build time and version to the end of each page")
+	flag.StringVar(&cfg.Version, "version", "", "the version of the site, defaults to the version of the module")
+	flag.StringVar(&cfg.DefaultOGImage, "og-image", "/images/enlighten-logo.png", "the image shown when pages are shared on social media, empty for none")
+	flag.IntVar(&cfg.PastEventsPageSize, "past-events-page-size", 0, "the most years of events on each past events page, 0 shows all years on one page")
+	flag.BoolVar(&cfg.Watch, "watch", false, "rebuild the site when the files in the -src folder change")
+	flag.DurationVar(&cfg.WatchInterval, "watch-interval", time.Second, "how often to check for changed files when watching")
+	flag.StringVar(&cfg.Src, "src", "", "the resources folder to read the site from instead of the resources built into the generator, such as internal/resources")
+	flag.StringVar(&cfg.DefaultRobotsTag, "robots", "noindex, nofollow", "the robots meta tag of pages, such as \"index, follow\", empty for none")
+	flag.BoolVar(&cfg.Precompress, "precompress", false, "write a gzipped copy of each html, css, and js file for the server to send to browsers that accept gzip")
+	flag.IntVar(&cfg.MaxOutputFileBytes, "max-output-file-bytes", mB5, "the largest allowed generated output file in bytes, 0 allows any size, copied binary files are not limited")
+	flag.StringVar(&cfg.BasePath, "base-path", "/", "the path the site is served under, such as /enlighten/, which prefixes the links of the site")
+	flag.Func("csp-file", "the path of a text file of the Content-Security-Policy header, such as csp.txt of the server, for the header files of other hosts", func(s string) error {
+		data, err := os.ReadFile(s)
+		if err != nil {
+			return fmt.Errorf("reading content security policy: %w", err)
+		}
+		cfg.ContentSecurityPolicy = strings.TrimSpace(string(data))
+		return nil
+	})
 	flag.Usage = usage
 	flag.Parse()
-	if len(cfg.Dest) == 0 {
+	if len(cfg.Dest) == 0 || (cfg.Watch && len(cfg.Src) == 0) {
 		flag.Usage()
 		os.Exit(2)
 	}
@@ -49,24 +256,176 @@ func main() {
 	// to debug the compilation of the site's web pages:
 	// func (cfg Config) WriteSite() {
 
-	if err := writeFiles(cfg.Dest, cfg.OneResource); err != nil {
-		fmt.Fprintf(os.Stderr, "generating site: %v\n", err)
+	err := writeFiles(cfg)
+	if err != nil {
+		writeError(os.Stderr, "generating site", err)
+	}
+	if cfg.Watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		w := watcher{
+			dir:      cfg.Src,
+			interval: cfg.WatchInterval,
+			build:    func() error { return writeFiles(cfg) },
+			out:      os.Stderr,
+		}
+		fmt.Fprintf(os.Stderr, "watching %v for changes, press Ctrl-C to stop\n", cfg.Src)
+		w.watch(ctx)
+		return
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
-func writeFiles(dest string, oneResource bool) error {
-	s := Site{
-		removeAll:   os.RemoveAll,
-		OneResource: oneResource,
-		mkdirAll:    func(path string) error { return os.MkdirAll(path, perm) },
-		writeFile:   func(name string, data []byte) error { return os.WriteFile(name, data, perm) },
-		isNotExist:  os.IsNotExist,
-		fSys:        _siteFS,
-		dest:        dest,
-		Name:        "Enl!ghten",
-		Description: "Kitsap Community Forum",
+func writeFiles(cfg Config) error {
+	s := newSite(cfg)
+	s.BuildTime = s.now()
+	var pw *plannedWrites
+	if cfg.DryRun {
+		pw = new(plannedWrites)
+		s.removeAll = func(path string) error { return nil }
+		s.mkdirAll = func(path string) error { return nil }
+		s.writeFile = pw.writeFile
+	}
+	var iw *incrementalWrites
+	if cfg.Incremental {
+		iw = new(incrementalWrites)
+		s.removeAll = func(path string) error { return nil }
+		s.writeFile = iw.conditionalWrite(os.ReadFile, s.writeFile)
+	}
+	s.limitWrites()
+	if !cfg.NoManifest {
+		s.recordWrites()
+	}
+	s.recordHTMLWrites()
+	if cfg.Precompress {
+		s.compressWrites()
+	}
+	var previousManifest map[string]string
+	if !cfg.NoManifest {
+		m, err := s.readBuildManifest(os.ReadFile)
+		if err != nil {
+			return fmt.Errorf("build manifest: %w", err)
+		}
+		previousManifest = m
+	}
+	if err := s.writeSite(); err != nil {
+		return err
+	}
+	if err := s.addSearchIndex(); err != nil {
+		return fmt.Errorf("search index: %w", err)
+	}
+	if !cfg.NoManifest {
+		if err := s.addChangelogFeed(s.manifestDiff(previousManifest)); err != nil {
+			return fmt.Errorf("changelog: %w", err)
+		}
+		if err := s.writeBuildManifest(); err != nil {
+			return fmt.Errorf("build manifest: %w", err)
+		}
+	}
+	if pw != nil {
+		pw.report(os.Stdout)
+	}
+	if iw != nil {
+		iw.report(os.Stdout)
+	}
+	return nil
+}
+
+// newSite creates a site from the config that writes to the filesystem.
+func newSite(cfg Config) *Site {
+	s := &Site{
+		removeAll:                 os.RemoveAll,
+		OneResource:               cfg.OneResource,
+		StrictPageNames:           cfg.StrictPageNames,
+		CompressPDFs:              cfg.CompressPDFs,
+		RequireNonEmptyDirs:       cfg.RequireNonEmptyDirs,
+		MaxFutureEvents:           cfg.MaxFutureEvents,
+		MaxFilenameLen:            cfg.MaxFilenameLen,
+		BaseURL:                   cfg.BaseURL,
+		GenerateHtaccess:          cfg.GenerateHtaccess,
+		GenerateNetlifyRedirects:  cfg.GenerateNetlifyRedirects,
+		RedirectMap:               cfg.RedirectMap,
+		GenerateVercelConfig:      cfg.GenerateVercelConfig,
+		MaxResourceSize:           mB10,
+		mkdirAll:                  func(path string) error { return os.MkdirAll(path, perm) },
+		writeFile:                 func(name string, data []byte) error { return os.WriteFile(name, data, perm) },
+		now:                       time.Now,
+		isNotExist:                os.IsNotExist,
+		pdfCompressor:             ghostscriptCompress,
+		logger:                    log.New(os.Stderr, "", 0),
+		mu:                        new(sync.Mutex),
+		baseTemplateOnce:          new(sync.Once),
+		fSys:                      _siteFS,
+		dest:                      cfg.Dest,
+		BundleCSS:                 cfg.BundleCSS,
+		ThemeColor:                cfg.ThemeColor,
+		GenerateBrowserConfig:     cfg.GenerateBrowserConfig,
+		ContributorNames:          cfg.ContributorNames,
+		Microformats:              cfg.Microformats,
+		DeprecatedTemplateBlocks:  cfg.DeprecatedTemplateBlocks,
+		GenerateEmailPages:        cfg.GenerateEmailPages,
+		ServePageAPI:              cfg.ServePageAPI,
+		SecurityContact:           cfg.SecurityContact,
+		SecurityEncryption:        cfg.SecurityEncryption,
+		GenerateDarkMode:          cfg.GenerateDarkMode,
+		ThemeColors:               cfg.ThemeColors,
+		PreloadResources:          cfg.PreloadResources,
+		GenerateLighthouseConfig:  cfg.GenerateLighthouseConfig,
+		LighthouseThresholds:      cfg.LighthouseThresholds,
+		GenerateCloudflareHeaders: cfg.GenerateCloudflareHeaders,
+		GenerateNextJSMetadata:    cfg.GenerateNextJSMetadata,
+		PlainTextEvents:           cfg.PlainTextEvents,
+		Languages:                 cfg.Languages,
+		SearchCorpus:              cfg.SearchCorpus,
+		BoardMembers:              cfg.BoardMembers,
+		EnableJS:                  cfg.EnableJS,
+		StrictA11y:                cfg.StrictA11y,
+		GenerateDockerfile:        cfg.GenerateDockerfile,
+		GenerateGHAWorkflow:       cfg.GenerateGHAWorkflow,
+		GenerateGallery:           cfg.GenerateGallery,
+		Verbose:                   cfg.Verbose,
+		EventLocation:             cfg.EventLocation,
+		GenerateAppShell:          cfg.GenerateAppShell,
+		GenerateVolunteersJSON:    cfg.GenerateVolunteersJSON,
+		FeedAuthor:                cfg.FeedAuthor,
+		FingerprintAssets:         cfg.FingerprintAssets,
+		Concurrency:               cfg.Concurrency,
+		SecurityExpires:           time.Now().AddDate(1, 0, 0),
+		Name:                      cfg.Name,
+		Description:               cfg.Description,
+		LenientDates:              cfg.LenientDates,
+		BuildComment:              cfg.BuildComment,
+		Version:                   siteVersion(cfg.Version),
+		DefaultOGImage:            cfg.DefaultOGImage,
+		PastEventsPageSize:        cfg.PastEventsPageSize,
+		DefaultRobotsTag:          cfg.DefaultRobotsTag,
+		MaxOutputFileBytes:        cfg.MaxOutputFileBytes,
+		BasePath:                  strings.TrimSuffix(cfg.BasePath, "/") + "/",
+		ContentSecurityPolicy:     cfg.ContentSecurityPolicy,
+		RepoDir:                   cfg.RepoDir,
+	}
+	if len(cfg.Src) != 0 {
+		s.fSys = newSrcFS(cfg.Src)
+	}
+	s.NavAriaLabel = s.Name + " navigation"
+	return s
+}
+
+// siteVersion is the version from the flag, or the version of the module if it is not set.
+func siteVersion(version string) string {
+	if len(version) != 0 {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
 	}
+	return info.Main.Version
+}
+
+func (s *Site) writeSite() error {
 	if err := s.cleanDest(); err != nil {
 		return fmt.Errorf("cleaning destination directory: %w", err)
 	}
@@ -74,7 +433,132 @@ func writeFiles(dest string, oneResource bool) error {
 		return fmt.Errorf("main site pages: %w", err)
 	}
 	if err := s.addEvents(); err != nil {
-		return fmt.Errorf("event pages: %w", err)
+		return wrapErrors("event pages", err)
+	}
+	if err := s.addDeployFiles(); err != nil {
+		return fmt.Errorf("deploy files: %w", err)
+	}
+	if err := s.addMetaFiles(); err != nil {
+		return fmt.Errorf("meta files: %w", err)
+	}
+	if s.ServePageAPI {
+		if err := s.addPageAPI(s.pages); err != nil {
+			return fmt.Errorf("page api: %w", err)
+		}
+	}
+	if s.GenerateNextJSMetadata {
+		if err := s.addNextJSMetadata(s.pages); err != nil {
+			return fmt.Errorf("next.js metadata: %w", err)
+		}
+	}
+	if s.SearchCorpus {
+		if err := s.addSearchCorpus(s.indexedPages); err != nil {
+			return fmt.Errorf("search corpus: %w", err)
+		}
 	}
 	return nil
 }
+
+// incrementalWrites counts the files written by an incremental build.
+type incrementalWrites struct {
+	mu        sync.Mutex
+	added     int
+	updated   int
+	unchanged int
+}
+
+// conditionalWrite creates a writeFile function that skips files whose contents have not changed.
+func (iw *incrementalWrites) conditionalWrite(readFile func(name string) ([]byte, error), writeFile func(name string, data []byte) error) func(name string, data []byte) error {
+	return func(name string, data []byte) error {
+		old, err := readFile(name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := writeFile(name, data); err != nil {
+				return err
+			}
+			iw.count(&iw.added)
+		case err != nil:
+			return fmt.Errorf("reading previous version of file: %w", err)
+		case sha256.Sum256(old) == sha256.Sum256(data):
+			iw.count(&iw.unchanged)
+		default:
+			if err := writeFile(name, data); err != nil {
+				return err
+			}
+			iw.count(&iw.updated)
+		}
+		return nil
+	}
+}
+
+func (iw *incrementalWrites) count(n *int) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	*n++
+}
+
+// report prints how many files were added, updated, and unchanged.
+func (iw *incrementalWrites) report(w io.Writer) {
+	fmt.Fprintf(w, "incremental build: %v added, %v updated, %v unchanged\n", iw.added, iw.updated, iw.unchanged)
+}
+
+// plannedWrites records the files that would be written by a dry run.
+type plannedWrites struct {
+	mu    sync.Mutex
+	sizes map[string]int
+}
+
+func (pw *plannedWrites) writeFile(name string, data []byte) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.sizes == nil {
+		pw.sizes = make(map[string]int)
+	}
+	pw.sizes[name] = len(data)
+	return nil
+}
+
+// paths lists the files that would be written in alphabetical order.
+func (pw *plannedWrites) paths() []string {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	paths := make([]string, 0, len(pw.sizes))
+	for p := range pw.sizes {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// report prints the files that would be written and their sizes.
+func (pw *plannedWrites) report(w io.Writer) {
+	total := 0
+	for _, p := range pw.paths() {
+		n := pw.sizes[p]
+		total += n
+		fmt.Fprintf(w, "%v (%v bytes)\n", p, n)
+	}
+	fmt.Fprintf(w, "dry run: would write %v files (%v bytes)\n", len(pw.sizes), total)
+}
+
+func ghostscriptCompress(data []byte) ([]byte, error) {
+	cmd := exec.Command("gs",
+		"-sDEVICE=pdfwrite",
+		"-dCompatibilityLevel=1.4",
+		"-dPDFSETTINGS=/printer",
+		"-dNOPAUSE",
+		"-dBATCH",
+		"-dQUIET",
+		"-sOutputFile=-",
+		"-",
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running ghostscript: %w: %s", err, stderr)
+	}
+	return stdout.Bytes(), nil
+}
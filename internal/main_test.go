@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestPlannedWrites(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/robots.txt"] = &fstest.MapFile{Data: []byte("User-agent: *")}
+	s := newTestSite(fSys)
+	pw := new(plannedWrites)
+	s.writeFile = pw.writeFile
+	if err := s.addStatic("", "", "robots.txt"); err != nil {
+		t.Fatalf("adding robots.txt: %v", err)
+	}
+	if err := s.addPage("Home", "", "home.html", nil); err != nil {
+		t.Fatalf("adding home page: %v", err)
+	}
+	if err := s.addStatic("", "", "robots.txt"); err != nil {
+		t.Fatalf("adding robots.txt again: %v", err)
+	}
+	if len(s.files) != 0 {
+		t.Errorf("wanted no files written, got %v", s.files)
+	}
+	want := []string{"dest/home.html", "dest/robots.txt"}
+	if got := pw.paths(); !reflect.DeepEqual(want, got) {
+		t.Errorf("paths not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+	var sb strings.Builder
+	pw.report(&sb)
+	wantReport := "dest/robots.txt (13 bytes)\ndry run: would write 2 files ("
+	if got := sb.String(); !strings.Contains(got, wantReport) {
+		t.Errorf("wanted report to contain %q, got %q", wantReport, got)
+	}
+}
+
+func TestWriteSiteNameAndDescription(t *testing.T) {
+	cfg := Config{
+		Dest:        "dest",
+		Name:        "Test Forum",
+		Description: "A Place For Tests",
+		Concurrency: 1,
+	}
+	s := newSite(cfg)
+	files := make(map[string][]byte)
+	s.removeAll = func(path string) error { return nil }
+	s.mkdirAll = func(path string) error { return nil }
+	s.writeFile = func(name string, data []byte) error {
+		files[name] = data
+		return nil
+	}
+	if err := s.writeSite(); err != nil {
+		t.Fatalf("writing site: %v", err)
+	}
+	home := string(files["dest/home.html"])
+	for _, want := range []string{cfg.Name, cfg.Description} {
+		if !strings.Contains(home, want) {
+			t.Errorf("wanted home page to contain %q", want)
+		}
+	}
+}
+
+func TestWriteSiteBasePath(t *testing.T) {
+	for _, oneResource := range []bool{true, false} {
+		cfg := Config{
+			Dest:            "dest",
+			Concurrency:     1,
+			BasePath:        "/enlighten/",
+			OneResource:     oneResource,
+			GenerateGallery: true,
+		}
+		s := newSite(cfg)
+		files := make(map[string][]byte)
+		s.removeAll = func(path string) error { return nil }
+		s.mkdirAll = func(path string) error { return nil }
+		s.writeFile = func(name string, data []byte) error {
+			files[name] = data
+			return nil
+		}
+		if err := s.writeSite(); err != nil {
+			t.Fatalf("writing site: %v", err)
+		}
+		rootURLRE := regexp.MustCompile(`\s(?:href|src)=["']/([^"']*)`)
+		for name, data := range files {
+			if !strings.HasSuffix(name, ".html") {
+				continue
+			}
+			for _, m := range rootURLRE.FindAllSubmatch(data, -1) {
+				if !strings.HasPrefix(string(m[1]), "enlighten/") {
+					t.Errorf("one resource = %v: %v has url outside of base path: %s", oneResource, name, m[0])
+				}
+			}
+		}
+	}
+}
+
+func TestWriteBuildManifest(t *testing.T) {
+	cfg := Config{
+		Dest:        "dest",
+		Concurrency: 1,
+	}
+	s := newSite(cfg)
+	files := make(map[string][]byte)
+	s.removeAll = func(path string) error { return nil }
+	s.mkdirAll = func(path string) error { return nil }
+	s.writeFile = func(name string, data []byte) error {
+		files[name] = data
+		return nil
+	}
+	s.now = func() time.Time {
+		return time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	}
+	s.recordWrites()
+	if err := s.writeSite(); err != nil {
+		t.Fatalf("writing site: %v", err)
+	}
+	if err := s.writeBuildManifest(); err != nil {
+		t.Fatalf("writing build manifest: %v", err)
+	}
+	var entries []buildManifestEntry
+	if err := json.Unmarshal(files["dest/manifest.json"], &entries); err != nil {
+		t.Fatalf("parsing build manifest: %v", err)
+	}
+	var want, got []string
+	for name := range files {
+		if name != "dest/manifest.json" {
+			want = append(want, strings.TrimPrefix(name, "dest/"))
+		}
+	}
+	slices.Sort(want)
+	for _, e := range entries {
+		got = append(got, e.Path)
+		data := files["dest/"+e.Path]
+		sum := sha256.Sum256(data)
+		if want, got := hex.EncodeToString(sum[:]), e.SHA256; want != got {
+			t.Errorf("hashes of %v not equal: wanted %v, got %v", e.Path, want, got)
+		}
+		if want, got := len(data), e.Size; want != got {
+			t.Errorf("sizes of %v not equal: wanted %v, got %v", e.Path, want, got)
+		}
+		if want, got := "2023-08-01T12:00:00Z", e.BuildTime; want != got {
+			t.Errorf("build times of %v not equal: wanted %v, got %v", e.Path, want, got)
+		}
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("manifest files not equal to written files: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestIncrementalBuild(t *testing.T) {
+	files := make(map[string][]byte)
+	readFile := func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return data, nil
+	}
+	writeFile := func(name string, data []byte) error {
+		files[name] = data
+		return nil
+	}
+	build := func() *incrementalWrites {
+		s := newSite(Config{Dest: "dest", Concurrency: 1})
+		iw := new(incrementalWrites)
+		s.removeAll = func(path string) error { return nil }
+		s.mkdirAll = func(path string) error { return nil }
+		s.writeFile = iw.conditionalWrite(readFile, writeFile)
+		s.now = func() time.Time {
+			return time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+		}
+		if err := s.writeSite(); err != nil {
+			t.Fatalf("writing site: %v", err)
+		}
+		return iw
+	}
+	first := build()
+	if first.added == 0 || first.updated != 0 || first.unchanged != 0 {
+		t.Errorf("wanted only added files in first build, got %v added, %v updated, %v unchanged", first.added, first.updated, first.unchanged)
+	}
+	second := build()
+	if want, got := 0, second.updated; want != got {
+		t.Errorf("wanted %v updated files in second build, got %v", want, got)
+	}
+	if want, got := 0, second.added; want != got {
+		t.Errorf("wanted %v added files in second build, got %v", want, got)
+	}
+	if want, got := first.added, second.unchanged; want != got {
+		t.Errorf("wanted %v unchanged files in second build, got %v", want, got)
+	}
+	files["dest/home.html"] = []byte("old")
+	third := build()
+	if want, got := 1, third.updated; want != got {
+		t.Errorf("wanted %v updated file after changing a file, got %v", want, got)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const humansTxt = `/* TEAM */
+{{- range .}}
+{{.}}
+{{- end}}
+
+/* SITE */
+Language: English
+Standards: HTML5, CSS3
+Software: Go
+`
+
+// addMetaFiles writes the files that describe the site to browsers and other tools.
+func (s *Site) addMetaFiles() error {
+	files := []struct {
+		enabled bool
+		name    string
+		add     func() error
+	}{
+		{s.GenerateBrowserConfig, "browserconfig.xml", s.addBrowserConfig},
+		{true, "humans.txt", s.addHumansTxt},
+		{len(s.SecurityContact) != 0, "security.txt", s.addSecurityTxt},
+		{true, "event frontmatter schema", s.addEventFrontmatterSchema},
+		{len(s.PreloadResources) != 0, "preload.json", s.addPreloadJSON},
+		{len(s.BoardMembers) != 0, "board-members.json", s.addBoardMembersJSON},
+		{s.GenerateVolunteersJSON, "volunteers.json", s.addVolunteerOpportunitiesJSON},
+	}
+	for _, f := range files {
+		if !f.enabled {
+			continue
+		}
+		if err := f.add(); err != nil {
+			return fmt.Errorf("adding %v: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+type (
+	browserConfig struct {
+		XMLName xml.Name `xml:"browserconfig"`
+		Tile    struct {
+			Logo struct {
+				Src string `xml:"src,attr"`
+			} `xml:"square150x150logo"`
+			TileColor string `xml:"TileColor"`
+		} `xml:"msapplication>tile"`
+	}
+)
+
+// addBrowserConfig writes the Windows tile settings for the site.
+func (s *Site) addBrowserConfig() error {
+	var cfg browserConfig
+	cfg.Tile.Logo.Src = s.urlPath("/images/enlighten-logo.png")
+	cfg.Tile.TileColor = s.ThemeColor
+	data, err := xml.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating xml: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	dest := path.Join(s.dest, "browserconfig.xml")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing browser config: %w", err)
+	}
+	return nil
+}
+
+// addHumansTxt writes the people who have contributed to the site.
+func (s *Site) addHumansTxt() error {
+	t := s.newTemplate("humans.txt")
+	if _, err := t.Parse(humansTxt); err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, s.ContributorNames); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	dest := path.Join(s.dest, "humans.txt")
+	if err := s.writeFile(dest, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing humans.txt: %w", err)
+	}
+	return nil
+}
+
+type pageMeta struct {
+	Path        string `json:"path"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Canonical   string `json:"canonical"`
+}
+
+// addPageAPI writes a json array that describes the pages.
+// The description is the social media description of the page, which is the description of the site if the page does not have its own.
+func (s *Site) addPageAPI(pages []Page) error {
+	metas := make([]pageMeta, len(pages))
+	for i, p := range pages {
+		metas[i] = pageMeta{
+			Path:        p.Path,
+			Title:       p.Name,
+			Description: p.Meta.OGDescription,
+			Canonical:   p.Canonical,
+		}
+	}
+	data, err := json.Marshal(metas)
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	destDir := path.Join(s.dest, "api")
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "pages.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing page api: %w", err)
+	}
+	return nil
+}
+
+// addNextJSMetadata writes a json object of the pages, keyed by their paths, for hydrating a Next.js app.
+func (s *Site) addNextJSMetadata(pages []Page) error {
+	m := make(map[string]Page, len(pages))
+	for _, p := range pages {
+		m[p.Path] = p
+	}
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	dest := path.Join(s.dest, "__next_export_data.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing next.js metadata: %w", err)
+	}
+	return nil
+}
+
+// addSecurityTxt writes where to report security issues, as described by RFC 9116.
+func (s *Site) addSecurityTxt() error {
+	contact := s.SecurityContact
+	if !strings.Contains(contact, ":") {
+		contact = "mailto:" + contact
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Contact: %v\n", contact)
+	fmt.Fprintf(&sb, "Expires: %v\n", s.SecurityExpires.UTC().Format(time.RFC3339))
+	if len(s.SecurityEncryption) != 0 {
+		fmt.Fprintf(&sb, "Encryption: %v\n", s.SecurityEncryption)
+	}
+	sb.WriteString("Preferred-Languages: en\n")
+	destDir := path.Join(s.dest, ".well-known")
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "security.txt")
+	if err := s.writeFile(dest, []byte(sb.String())); err != nil {
+		return fmt.Errorf("writing security.txt: %w", err)
+	}
+	return nil
+}
+
+// addEventFrontmatterSchema writes a JSON Schema of the meta comment that event files can start with.
+// Fields without omitempty in their json tags are required.
+func (s *Site) addEventFrontmatterSchema() error {
+	properties := make(map[string]interface{})
+	required := []string{}
+	t := reflect.TypeOf(EventMeta{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		properties[name] = jsonSchemaType(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "Event frontmatter",
+		"description":          `The JSON in the {{/* meta: {...} */}} comment at the start of an event file.`,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	data, err := json.MarshalIndent(schema, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	destDir := path.Join(s.dest, "docs")
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "event-frontmatter.schema.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing schema: %w", err)
+	}
+	return nil
+}
+
+func jsonSchemaType(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaType(t.Elem())}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// addPreloadJSON writes the preload resources so the server can send them as Link headers with html pages.
+func (s *Site) addPreloadJSON() error {
+	data, err := json.Marshal(s.PreloadResources)
+	if err != nil {
+		return fmt.Errorf("creating preload json: %w", err)
+	}
+	dest := path.Join(s.dest, "preload.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing preload json: %w", err)
+	}
+	return nil
+}
+
+// parseBoardMembers reads the json array of board members.
+// The members are json rather than yaml so the generator does not need a yaml module dependency.
+func parseBoardMembers(data []byte) ([]BoardMember, error) {
+	var members []BoardMember
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("parsing board members: %w", err)
+	}
+	for i, m := range members {
+		if len(m.Name) == 0 {
+			return nil, fmt.Errorf("board member %v has no name", i)
+		}
+	}
+	return members, nil
+}
+
+// addBoardMembersJSON writes the board members for the directories of partner organizations.
+func (s *Site) addBoardMembersJSON() error {
+	data, err := json.MarshalIndent(s.BoardMembers, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	destDir := path.Join(s.dest, about)
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "board-members.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing board members: %w", err)
+	}
+	return nil
+}
+
+type volunteerOpportunity struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+var (
+	templateCommentRE        = regexp.MustCompile(`(?s)\{\{-?\s*/\*.*?\*/\s*-?\}\}`)
+	volunteerOpportunityRE   = regexp.MustCompile(`<[a-z]+\s[^>]*\bdata-volunteer-opportunity\b[^>]*>`)
+	volunteerOpportunityAttr = regexp.MustCompile(`\bdata-(title|description)="([^"]*)"`)
+)
+
+// addVolunteerOpportunitiesJSON writes the volunteer opportunities that are marked up with data attributes on the volunteers page.
+func (s *Site) addVolunteerOpportunitiesJSON() error {
+	src := path.Join(resources, about, "volunteers.html")
+	data, err := fs.ReadFile(s.fSys, src)
+	if err != nil {
+		return fmt.Errorf("reading volunteers page: %w", err)
+	}
+	data = templateCommentRE.ReplaceAll(data, nil)
+	opportunities := []volunteerOpportunity{}
+	for _, tag := range volunteerOpportunityRE.FindAll(data, -1) {
+		var o volunteerOpportunity
+		for _, m := range volunteerOpportunityAttr.FindAllSubmatch(tag, -1) {
+			v := html.UnescapeString(string(m[2]))
+			switch string(m[1]) {
+			case "title":
+				o.Title = v
+			case "description":
+				o.Description = v
+			}
+		}
+		if len(o.Title) == 0 {
+			return fmt.Errorf("volunteer opportunity without data-title in %v: %s", src, tag)
+		}
+		opportunities = append(opportunities, o)
+	}
+	b, err := json.MarshalIndent(opportunities, "", "\t")
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	destDir := path.Join(s.dest, about)
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "volunteers.json")
+	if err := s.writeFile(dest, b); err != nil {
+		return fmt.Errorf("writing volunteer opportunities: %w", err)
+	}
+	return nil
+}
+
+const buildManifestName = "manifest.json"
+
+// buildManifestEntry describes a file that was written to the site.
+type buildManifestEntry struct {
+	Path      string `json:"path"`
+	Size      int    `json:"size"`
+	SHA256    string `json:"sha256"`
+	BuildTime string `json:"buildTime"`
+}
+
+// recordWrites remembers the size and hash of each file the site writes for the build manifest.
+func (s *Site) recordWrites() {
+	writeFile := s.writeFile
+	s.writeFile = func(name string, data []byte) error {
+		if err := writeFile(name, data); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(name, s.dest+"/") {
+			return nil // not part of the site, such as deploy/Dockerfile
+		}
+		sum := sha256.Sum256(data)
+		e := buildManifestEntry{
+			Path:   strings.TrimPrefix(strings.TrimPrefix(name, s.dest), "/"),
+			Size:   len(data),
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.writtenFiles == nil {
+			s.writtenFiles = make(map[string]buildManifestEntry)
+		}
+		s.writtenFiles[e.Path] = e
+		return nil
+	}
+}
+
+// writeBuildManifest writes manifest.json, which lists the files that were recorded while the site was written.
+func (s *Site) writeBuildManifest() error {
+	buildTime := s.now().UTC().Format(time.RFC3339)
+	entries := make([]buildManifestEntry, 0, len(s.writtenFiles))
+	for p, e := range s.writtenFiles {
+		if p == buildManifestName {
+			continue
+		}
+		e.BuildTime = buildTime
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b buildManifestEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshalling build manifest: %w", err)
+	}
+	dest := path.Join(s.dest, buildManifestName)
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing build manifest: %w", err)
+	}
+	return nil
+}
+
+// readBuildManifest reads the hashes of the files in the manifest of the previous build, keyed by path.
+// The hashes are nil if there was no previous build.
+func (s *Site) readBuildManifest(readFile func(name string) ([]byte, error)) (map[string]string, error) {
+	data, err := readFile(path.Join(s.dest, buildManifestName))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("reading previous build manifest: %w", err)
+	}
+	var entries []buildManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing previous build manifest: %w", err)
+	}
+	hashes := make(map[string]string, len(entries))
+	for _, e := range entries {
+		hashes[e.Path] = e.SHA256
+	}
+	return hashes, nil
+}
+
+// manifestDiff lists the pages that were added or changed since the previous build, ordered by path.
+// Nothing has changed if there was no previous build to compare to.
+func (s *Site) manifestDiff(previous map[string]string) []ManifestDiff {
+	if previous == nil {
+		return nil
+	}
+	var changes []ManifestDiff
+	for _, p := range s.pages {
+		e, ok := s.writtenFiles[strings.TrimPrefix(p.Path, "/")]
+		if !ok {
+			continue
+		}
+		c := ManifestDiff{
+			Path:  p.Path,
+			Title: p.Name,
+			Date:  s.BuildTime,
+		}
+		switch sha256, ok := previous[e.Path]; {
+		case !ok:
+			c.Status = "added"
+		case sha256 != e.SHA256:
+			c.Status = "changed"
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+	slices.SortFunc(changes, func(a, b ManifestDiff) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes
+}
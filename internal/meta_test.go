@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestAddBrowserConfig(t *testing.T) {
+	s := newTestSite(nil)
+	s.GenerateBrowserConfig = true
+	s.ThemeColor = "#123456"
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got browserConfig
+	if err := xml.Unmarshal(s.files["dest/browserconfig.xml"], &got); err != nil {
+		t.Fatalf("parsing browserconfig.xml: %v", err)
+	}
+	if want, got := "#123456", got.Tile.TileColor; want != got {
+		t.Errorf("wanted TileColor %q, got %q", want, got)
+	}
+}
+
+func TestAddHumansTxt(t *testing.T) {
+	s := newTestSite(nil)
+	s.ContributorNames = []string{"Jane Doe", "John Smith"}
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/humans.txt"])
+	if want := "/* TEAM */\nJane Doe\nJohn Smith\n\n/* SITE */"; !strings.Contains(got, want) {
+		t.Errorf("wanted humans.txt to contain %q, got:\n%v", want, got)
+	}
+}
+
+func TestAddPageAPI(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/about/contact-us.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}contact{{end}}`)}
+	s := newTestSite(fSys)
+	s.BaseURL = "https://example.com"
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("adding home page: %v", err)
+	}
+	meta := PageMeta{OGDescription: "Contact Description"}
+	if err := s.addPageWithMeta("Contact Us", "", "about", "contact-us.html", "contact-us.html", meta, nil); err != nil {
+		t.Fatalf("adding contact page: %v", err)
+	}
+	if err := s.addPageAPI(s.pages); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got []pageMeta
+	if err := json.Unmarshal(s.files["dest/api/pages.json"], &got); err != nil {
+		t.Fatalf("parsing page api: %v", err)
+	}
+	want := []pageMeta{
+		{"/home.html", "Home Page", "Test Description", "https://example.com/"},
+		{"/contact-us.html", "Contact Us", "Contact Description", "https://example.com/contact-us.html"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestAddNextJSMetadata(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/about/contact-us.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}contact{{end}}`)}
+	s := newTestSite(fSys)
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("adding home page: %v", err)
+	}
+	if err := s.addPage("Contact Us", "about", "contact-us.html", "data"); err != nil {
+		t.Fatalf("adding contact page: %v", err)
+	}
+	if err := s.addNextJSMetadata(s.pages); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(s.files["dest/__next_export_data.json"], &got); err != nil {
+		t.Fatalf("parsing next.js metadata: %v", err)
+	}
+	want := map[string]string{
+		"/home.html":       `{"name":"Home Page","path":"/home.html","wordCount":1}`,
+		"/contact-us.html": `{"name":"Contact Us","path":"/contact-us.html","wordCount":1}`,
+	}
+	if want, got := len(want), len(got); want != got {
+		t.Errorf("wanted %v pages, got %v", want, got)
+	}
+	for p, wantPage := range want {
+		var b bytes.Buffer
+		if err := json.Compact(&b, got[p]); err != nil {
+			t.Errorf("page %v missing or invalid: %v", p, err)
+			continue
+		}
+		if got := b.String(); wantPage != got {
+			t.Errorf("page %v: wanted %v, got %v", p, wantPage, got)
+		}
+	}
+}
+
+func TestAddSecurityTxt(t *testing.T) {
+	s := newTestSite(nil)
+	s.SecurityContact = "security@example.com"
+	s.SecurityExpires = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/.well-known/security.txt"])
+	want := "Contact: mailto:security@example.com\n" +
+		"Expires: 2030-01-02T03:04:05Z\n" +
+		"Preferred-Languages: en\n"
+	if want != got {
+		t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestAddEventFrontmatterSchema(t *testing.T) {
+	s := newTestSite(nil)
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.files["dest/docs/event-frontmatter.schema.json"], &got); err != nil {
+		t.Fatalf("parsing schema: %v", err)
+	}
+	for _, key := range []string{"$schema", "properties"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("wanted schema to have %q key: %v", key, got)
+		}
+	}
+	properties, ok := got["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("wanted properties to be an object: %v", got["properties"])
+	}
+	if _, ok := properties["name"]; !ok {
+		t.Errorf("wanted name property: %v", properties)
+	}
+}
+
+func TestAddPreloadJSON(t *testing.T) {
+	fSys := testMainFS()
+	mainHTML, err := fs.ReadFile(_siteFS, "resources/main.html")
+	if err != nil {
+		t.Fatalf("reading main template: %v", err)
+	}
+	fSys["resources/main.html"] = &fstest.MapFile{Data: mainHTML}
+	s := newTestSite(fSys)
+	s.PreloadResources = []PreloadResource{
+		{URL: "https://fonts.example.com/a.css", As: "style"},
+		{URL: "https://fonts.example.com/b.woff2", As: "font", CrossOrigin: "anonymous"},
+	}
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := `[{"url":"https://fonts.example.com/a.css","as":"style"},{"url":"https://fonts.example.com/b.woff2","as":"font","crossorigin":"anonymous"}]`
+	if got := string(s.files["dest/preload.json"]); want != got {
+		t.Errorf("preload json not equal:\nwanted: %v\ngot:    %v", want, got)
+	}
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("adding page: %v", err)
+	}
+	page := string(s.files["dest/home.html"])
+	wantTags := []string{
+		`<link rel="preload" href="https://fonts.example.com/a.css" as="style">`,
+		`<link rel="preload" href="https://fonts.example.com/b.woff2" as="font" crossorigin="anonymous">`,
+	}
+	for _, want := range wantTags {
+		if !strings.Contains(page, want) {
+			t.Errorf("wanted page to contain %q, got:\n%v", want, page)
+		}
+	}
+}
+
+func TestAddBoardMembersJSON(t *testing.T) {
+	fixture := `[
+	{"name": "Jane Doe", "role": "President", "photoURL": "/images/board/jane-doe.jpg"},
+	{"name": "John Smith", "role": "Treasurer"}
+]`
+	members, err := parseBoardMembers([]byte(fixture))
+	if err != nil {
+		t.Fatalf("parsing board members: %v", err)
+	}
+	s := newTestSite(nil)
+	s.BoardMembers = members
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got []BoardMember
+	if err := json.Unmarshal(s.files["dest/about/board-members.json"], &got); err != nil {
+		t.Fatalf("parsing board-members.json: %v", err)
+	}
+	want := []BoardMember{
+		{"Jane Doe", "President", "/images/board/jane-doe.jpg"},
+		{"John Smith", "Treasurer", ""},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestParseBoardMembersNoName(t *testing.T) {
+	if _, err := parseBoardMembers([]byte(`[{"role": "Secretary"}]`)); err == nil {
+		t.Errorf("wanted error for board member without name")
+	}
+}
+
+func TestAddVolunteerOpportunitiesJSON(t *testing.T) {
+	fSys := fstest.MapFS{
+		"resources/about/volunteers.html": &fstest.MapFile{Data: []byte(`{{define "content"}}
+{{/* <li data-volunteer-opportunity data-title="Example">Example</li> */}}
+<ul>
+<li data-volunteer-opportunity data-title="Greeter" data-description="Welcome guests at events">Greeter</li>
+<li class="x" data-description="Set up &amp; take down chairs" data-title="Setup" data-volunteer-opportunity>Setup</li>
+<li data-title="Not an opportunity">other</li>
+</ul>
+{{end}}`)},
+	}
+	s := newTestSite(fSys)
+	s.GenerateVolunteersJSON = true
+	if err := s.addMetaFiles(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var got []map[string]string
+	if err := json.Unmarshal(s.files["dest/about/volunteers.json"], &got); err != nil {
+		t.Fatalf("parsing volunteers.json: %v", err)
+	}
+	want := []map[string]string{
+		{"title": "Greeter", "description": "Welcome guests at events"},
+		{"title": "Setup", "description": "Set up & take down chairs"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestReadBuildManifest(t *testing.T) {
+	s := newTestSite(nil)
+	t.Run("no previous build", func(t *testing.T) {
+		readFile := func(name string) ([]byte, error) {
+			return nil, fs.ErrNotExist
+		}
+		got, err := s.readBuildManifest(readFile)
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		if got != nil {
+			t.Errorf("wanted no hashes, got %v", got)
+		}
+	})
+	t.Run("previous build", func(t *testing.T) {
+		readFile := func(name string) ([]byte, error) {
+			if want := "dest/manifest.json"; name != want {
+				t.Errorf("wanted to read %q, got %q", want, name)
+			}
+			return []byte(`[{"path":"home.html","size":1,"sha256":"abc","buildTime":"2023-07-01T12:00:00Z"}]`), nil
+		}
+		got, err := s.readBuildManifest(readFile)
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		if want := map[string]string{"home.html": "abc"}; !reflect.DeepEqual(want, got) {
+			t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+		}
+	})
+}
+
+func TestManifestDiff(t *testing.T) {
+	s := newTestSite(nil)
+	s.BuildTime = s.now()
+	s.pages = []Page{
+		{Name: "Home Page", Path: "/home.html"},
+		{Name: "Contact Us", Path: "/contact-us.html"},
+		{Name: "About", Path: "/about.html"},
+	}
+	s.writtenFiles = map[string]buildManifestEntry{
+		"home.html":       {Path: "home.html", SHA256: "new"},
+		"contact-us.html": {Path: "contact-us.html", SHA256: "same"},
+		"about.html":      {Path: "about.html", SHA256: "about"},
+	}
+	previous := map[string]string{
+		"home.html":       "old",
+		"contact-us.html": "same",
+	}
+	want := []ManifestDiff{
+		{Path: "/about.html", Title: "About", Status: "added", Date: s.BuildTime},
+		{Path: "/home.html", Title: "Home Page", Status: "changed", Date: s.BuildTime},
+	}
+	if got := s.manifestDiff(previous); !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	if got := s.manifestDiff(nil); len(got) != 0 {
+		t.Errorf("wanted no changes without a previous build, got %v", got)
+	}
+}
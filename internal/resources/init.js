@@ -0,0 +1,59 @@
+"use strict";
+
+// lazily load images with a data-src attribute when they scroll into view
+(function () {
+	var images = document.querySelectorAll("img[data-src]");
+	function load(img) {
+		img.src = img.dataset.src;
+		img.removeAttribute("data-src");
+	}
+	if (!("IntersectionObserver" in window)) {
+		images.forEach(load);
+		return;
+	}
+	var observer = new IntersectionObserver(function (entries) {
+		entries.forEach(function (entry) {
+			if (entry.isIntersecting) {
+				load(entry.target);
+				observer.unobserve(entry.target);
+			}
+		});
+	}, { rootMargin: "200px" });
+	images.forEach(function (img) {
+		observer.observe(img);
+	});
+})();
+
+// smoothly scroll to anchors on the page
+document.querySelectorAll('a[href^="#"]').forEach(function (a) {
+	a.addEventListener("click", function (event) {
+		var target = document.getElementById(a.getAttribute("href").slice(1));
+		if (target) {
+			event.preventDefault();
+			target.scrollIntoView({ behavior: "smooth" });
+			history.pushState(null, "", a.getAttribute("href"));
+		}
+	});
+});
+
+// show buttons with the back-to-top class after scrolling down the page
+(function () {
+	var buttons = document.querySelectorAll(".back-to-top");
+	if (buttons.length === 0) {
+		return;
+	}
+	function update() {
+		var hidden = window.scrollY < window.innerHeight;
+		buttons.forEach(function (b) {
+			b.hidden = hidden;
+		});
+	}
+	buttons.forEach(function (b) {
+		b.addEventListener("click", function (event) {
+			event.preventDefault();
+			window.scrollTo({ top: 0, behavior: "smooth" });
+		});
+	});
+	window.addEventListener("scroll", update, { passive: true });
+	update();
+})();
@@ -2,64 +2,235 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
+	"log"
+	"net/url"
 	"path"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type (
 	Data struct {
-		Site Site
-		Page Page
+		Site   Site
+		Page   Page
+		Assets map[string]string // the fingerprinted paths of stylesheets and images
 	}
 	Site struct {
-		fSys        fs.FS
-		dest        string
-		OneResource bool
-		Name        string
-		Description string
-		removeAll   func(path string) error
-		mkdirAll    func(path string) error
-		writeFile   func(name string, data []byte) error
-		isNotExist  func(err error) bool
+		fSys                      fs.FS
+		dest                      string
+		OneResource               bool
+		StrictPageNames           bool
+		CompressPDFs              bool
+		RequireNonEmptyDirs       bool
+		MaxFutureEvents           int
+		PastEventsPageSize        int
+		MaxFilenameLen            int
+		MaxResourceSize           int
+		MaxOutputFileBytes        int
+		BundleCSS                 bool
+		FingerprintAssets         bool
+		Concurrency               int
+		LenientDates              bool
+		BuildTime                 time.Time
+		Version                   string
+		BuildComment              bool
+		Microformats              bool
+		GenerateEmailPages        bool
+		ServePageAPI              bool
+		DeprecatedTemplateBlocks  []string
+		Name                      string
+		Description               string
+		NavAriaLabel              string
+		BaseURL                   string
+		BasePath                  string
+		ContentSecurityPolicy     string // the Content-Security-Policy header of the server, written into the header files of other hosts if set
+		ThemeColor                string
+		DefaultOGImage            string
+		DefaultRobotsTag          string
+		GenerateDarkMode          bool
+		ContributorNames          []string
+		ThemeColors               []string
+		PreloadResources          []PreloadResource
+		GenerateLighthouseConfig  bool
+		LighthouseThresholds      map[string]float64
+		GenerateCloudflareHeaders bool
+		GenerateNextJSMetadata    bool
+		PlainTextEvents           bool
+		Languages                 []string
+		SearchCorpus              bool
+		BoardMembers              []BoardMember
+		EnableJS                  bool
+		StrictA11y                bool
+		GenerateDockerfile        bool
+		RepoDir                   string // the root of the repository, where files that are not served with the site are written
+		GenerateGHAWorkflow       bool
+		GenerateGallery           bool
+		Verbose                   bool
+		EventLocation             string
+		GenerateAppShell          bool
+		GenerateVolunteersJSON    bool
+		FeedAuthor                string
+		SecurityContact           string
+		SecurityEncryption        string
+		SecurityExpires           time.Time
+		GenerateHtaccess          bool
+		GenerateNetlifyRedirects  bool
+		RedirectMap               map[string]string
+		GenerateVercelConfig      bool
+		GenerateBrowserConfig     bool
+		removeAll                 func(path string) error
+		mkdirAll                  func(path string) error
+		writeFile                 func(name string, data []byte) error
+		isNotExist                func(err error) bool
+		pdfCompressor             func(data []byte) ([]byte, error)
+		now                       func() time.Time
+		logger                    *log.Logger
+		mu                        *sync.Mutex // guards the fields below, which are written while past event years are added concurrently
+		baseTemplateOnce          *sync.Once
+		baseTemplate              *template.Template // main.html and the files it uses, parsed once
+		baseTemplateErr           error
+		pageNames                 map[string]string
+		pages                     []Page
+		indexedPages              []indexedPage
+		futureEvents              *EventGroup
+		pastEvents                []EventGroup
+		assetManifest             map[string]string
+		writtenFiles              map[string]buildManifestEntry
+		htmlFiles                 map[string][]byte
 	}
 	Page struct {
-		Name string
-		Data interface{}
+		Name        string        `json:"name"`
+		Title       string        `json:"title,omitempty"` // the title of the browser tab, the name and site name if empty
+		Path        string        `json:"path"`
+		Lang        string        `json:"lang,omitempty"`
+		Alternates  []Alternate   `json:"alternates,omitempty"`
+		WordCount   int           `json:"wordCount,omitempty"`
+		ReadingTime time.Duration `json:"-"`
+		Robots      string        `json:"robots,omitempty"`    // how search engines should index the page, no meta tag is written if empty
+		Canonical   string        `json:"canonical,omitempty"` // the preferred url of the page, which is only set if the site has a BaseURL
+		Meta        PageMeta      `json:"-"`
+		Data        interface{}   `json:"-"` // only used to execute the template
+	}
+	// PageMeta describes the page when it is shared on social media.
+	PageMeta struct {
+		OGImage       string // the absolute url of the preview image, if any
+		OGDescription string
+		TwitterCard   string // summary_large_image when there is an image, otherwise summary
+	}
+	// Alternate is a version of a page in another language.
+	Alternate struct {
+		Lang string `json:"lang"`
+		Href string `json:"href"`
 	}
 	EventGroup struct {
 		Year      string
 		Events    bytes.Buffer
 		Resources bytes.Buffer
+		Documents bytes.Buffer // links to the documents of the events, which are on the resources page if there is only one
+		Entries   []EventEntry
+		Files     []ResourceFile
+		Images    []string
+	}
+	PastEvents struct {
+		Years        []EventGroup
+		Microformats bool
+	}
+	// PaginatedEventData is the past events for one page of past events.
+	PaginatedEventData struct {
+		PastEvents
+		PageNumber int
+		TotalPages int
+		PrevPath   string // the page of newer events, if any
+		NextPath   string // the page of older events, if any
+	}
+	EventEntry struct {
+		File          string
+		Title         string
+		Speaker       string
+		Date          time.Time
+		StartDate     time.Time // from the frontmatter of the event, if set
+		ResourcesHref string
+		Content       string
+		Resources     string
+		eventsEnd     int
+		resourcesEnd  int
+	}
+	// GalleryImage is a photo from an event.
+	GalleryImage struct {
+		Src  string
+		Alt  string
+		Year string
+	}
+	// BoardMember is a person who serves on the board of the organization.
+	BoardMember struct {
+		Name     string `json:"name"`
+		Role     string `json:"role,omitempty"`
+		PhotoURL string `json:"photoURL,omitempty"`
+	}
+	// PreloadResource is an external stylesheet, script, or font that browsers should fetch early.
+	PreloadResource struct {
+		URL         string `json:"url"`
+		As          string `json:"as"`
+		CrossOrigin string `json:"crossorigin,omitempty"`
+	}
+	// ResourceFile is a downloadable document for an event.
+	ResourceFile struct {
+		Path    string
+		Size    int64
+		ModTime time.Time
+	}
+	// EventMeta is the optional frontmatter of an event file, a JSON comment such as:
+	// {{/* meta: {"name": "Jane Doe: Local Birds"} */}}
+	EventMeta struct {
+		Name        string `json:"name,omitempty"`
+		Speaker     string `json:"speaker,omitempty"`
+		StartDate   string `json:"startDate,omitempty"` // such as 2024-01-19 or 2024-01-19T19:00:00-08:00
+		Location    string `json:"location,omitempty"`
+		Description string `json:"description,omitempty"`
 	}
 )
 
+var eventMetaRE = regexp.MustCompile(`(?s)^\s*\{\{-?\s*/\*\s*meta:(.*?)\*/\s*-?\}\}`)
+
 func (s *Site) addMain() error {
+	if err := s.validateStylesheets(); err != nil {
+		return err
+	}
 	pages := []struct {
 		srcDir   string
 		fileName string
 		name     string
+		title    string
+		data     interface{}
 	}{
-		{"", "home", "Home Page"},
-		{about, "board-members", "Board Members"},
-		{about, "contact-us", "Contact Us"},
-		{about, "donations", "Donations"},
-		{about, "location", "Where Are We Located?"},
-		{about, "mission-statement", "Mission Statement"},
-		{about, "purpose-statement", "Purpose Statement"},
-		{about, "volunteers", "Volunteers"},
-		{events, "calendar", "Calendar"},
-		{events, "meeting-link", "Zoom Meeting Link"},
-		{events, "sign-up", "Sign Up For Events"},
-	}
-	for _, pg := range pages {
-		if err := s.addPage(pg.name, pg.srcDir, pg.fileName+".html", nil); err != nil {
-			return fmt.Errorf("writing page: %w", err)
-		}
+		{"", "home", "Home Page", s.Name + ": " + s.Description, nil},
+		{"", "maintenance", "Down For Maintenance", "", nil},
+		{about, "board-members", "Board Members", "", s.BoardMembers},
+		{about, "contact-us", "Contact Us", "", nil},
+		{about, "donations", "Donations", "", nil},
+		{about, "location", "Where Are We Located?", "", nil},
+		{about, "mission-statement", "Mission Statement", "", nil},
+		{about, "purpose-statement", "Purpose Statement", "", nil},
+		{about, "volunteers", "Volunteers", "", nil},
+		{events, "calendar", "Calendar", "Calendar of Events | " + s.Name, nil},
+		{events, "meeting-link", "Zoom Meeting Link", "", nil},
+		{events, "sign-up", "Sign Up For Events", "", nil},
 	}
 	imageDirs := []struct {
 		src     string
@@ -79,6 +250,77 @@ func (s *Site) addMain() error {
 	if err := s.addStatic("", "", "robots.txt"); err != nil {
 		return fmt.Errorf("adding robots.txt: %w", err)
 	}
+	if s.BundleCSS {
+		if err := s.addCSSBundle(); err != nil {
+			return fmt.Errorf("adding css bundle: %w", err)
+		}
+	}
+	if s.EnableJS {
+		if err := s.addInitJS(); err != nil {
+			return fmt.Errorf("adding init.js: %w", err)
+		}
+	}
+	if s.GenerateDarkMode {
+		if err := s.addDarkModeCSS(); err != nil {
+			return fmt.Errorf("adding dark mode css: %w", err)
+		}
+	}
+	// pages are written after the stylesheets and images so links to fingerprinted assets can be rewritten
+	for _, pg := range pages {
+		srcName := pg.fileName + ".html"
+		if err := s.addTitledPage(pg.name, pg.title, pg.srcDir, srcName, pg.data); err != nil {
+			return fmt.Errorf("writing page: %w", err)
+		}
+		for _, lang := range s.Languages {
+			if err := s.addTranslatedPage(pg.name, pg.title, lang, pg.srcDir, srcName, pg.data); err != nil {
+				return fmt.Errorf("writing translated page: %w", err)
+			}
+		}
+	}
+	if err := s.add404Page(); err != nil {
+		return fmt.Errorf("adding 404 page: %w", err)
+	}
+	if s.GenerateAppShell {
+		if err := s.addAppShell(); err != nil {
+			return fmt.Errorf("adding app shell: %w", err)
+		}
+	}
+	return nil
+}
+
+// add404Page writes the page the server sends when a file is not found.
+func (s *Site) add404Page() error {
+	return s.addPage("Page Not Found", "", "404.html", nil)
+}
+
+// addCSSBundle combines the stylesheets in the css folder in alphabetical order.
+func (s *Site) addCSSBundle() error {
+	srcDir := path.Join(resources, "css")
+	entries, err := fs.ReadDir(s.fSys, srcDir)
+	if err != nil {
+		return fmt.Errorf("reading css directory: %w", err)
+	}
+	var bundle bytes.Buffer
+	for _, f := range entries {
+		n := f.Name()
+		if f.IsDir() || path.Ext(n) != ".css" {
+			return fmt.Errorf("unexpected file in css directory: %q", n)
+		}
+		b, err := fs.ReadFile(s.fSys, path.Join(srcDir, n))
+		if err != nil {
+			return fmt.Errorf("reading stylesheet: %w", err)
+		}
+		fmt.Fprintf(&bundle, "/* %v */\n", n)
+		bundle.Write(b)
+		bundle.WriteString("\n")
+	}
+	dest := s.fingerprint(path.Join(s.dest, "bundle.css"), bundle.Bytes())
+	if err := s.checkFilenameLen(dest); err != nil {
+		return err
+	}
+	if err := s.writeFile(dest, bundle.Bytes()); err != nil {
+		return fmt.Errorf("writing css bundle: %w", err)
+	}
 	return nil
 }
 
@@ -106,8 +348,20 @@ func (s *Site) addImages(srcDir, destDir string, maxSize int) error {
 			return fmt.Errorf("unexpected directory for images: %q", nn)
 		}
 		switch ext := path.Ext(nn); ext {
-		case ".png", ".jpg":
-			if err := s.addImage(f, srcDir, destDir, maxSize); err != nil {
+		case ".png", ".jpg", ".webp", ".gif", ".svg":
+			size := maxSize
+			if ext == ".svg" {
+				size = min(maxSize, kB10)
+			}
+			b, err := s.readImage(f, srcDir, size)
+			if err != nil {
+				return fmt.Errorf("adding image: %w", err)
+			}
+			if ext == ".svg" && svgScriptRE.Match(b) {
+				return fmt.Errorf("svg image %q contains a script", nn)
+			}
+			destP := s.fingerprint(path.Join(s.dest, destDir, nn), b)
+			if err := s.writeImage(destP, b); err != nil {
 				return fmt.Errorf("adding image: %w", err)
 			}
 		default:
@@ -117,100 +371,566 @@ func (s *Site) addImages(srcDir, destDir string, maxSize int) error {
 	return nil
 }
 
-func (s *Site) addImage(f fs.DirEntry, src, destDir string, maxSize int) error {
+var svgScriptRE = regexp.MustCompile(`(?i)<script`)
+
+// addBinaryFile copies the file, such as an image, document, or recording, to the folder.
+// The path of the copy is returned relative to the destination of the site, which is also its url path.
+func (s *Site) addBinaryFile(f fs.DirEntry, src, destDir string, maxSize int) (string, error) {
+	b, err := s.readImage(f, src, maxSize)
+	if err != nil {
+		return "", err
+	}
+	destPath := path.Join("/", destDir, f.Name())
+	if err := s.writeImage(path.Join(s.dest, destPath), b); err != nil {
+		return "", err
+	}
+	return destPath, nil
+}
+
+// binaryFileTypes are the media types of the binary files the site copies.
+var binaryFileTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".pdf":  "application/pdf",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+}
+
+// mimeTypeFor is the media type of files with the extension, such as ".pdf".
+// Unknown extensions are generic binary data.
+func mimeTypeFor(ext string) string {
+	if t, ok := binaryFileTypes[strings.ToLower(ext)]; ok {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func (s *Site) readImage(f fs.DirEntry, src string, maxSize int) ([]byte, error) {
 	if f.IsDir() {
-		return fmt.Errorf("will not read directory from image folder")
+		return nil, fmt.Errorf("will not read directory from image folder")
 	}
 	n := f.Name()
 	srcP := path.Join(src, n)
 	b, err := fs.ReadFile(s.fSys, srcP)
+	if err == nil && s.CompressPDFs && path.Ext(n) == ".pdf" {
+		b, err = s.compressPDF(b)
+	}
 	if len(b) > maxSize && maxSize > 0 {
-		return fmt.Errorf("image %q larger than %v bytes", n, maxSize)
+		return nil, fmt.Errorf("image %q larger than %v bytes", n, maxSize)
 	}
 	if err != nil {
-		return fmt.Errorf("reading image: %w", err)
+		return nil, fmt.Errorf("reading image: %w", err)
 	}
-	dest := path.Join(s.dest, destDir)
-	if err := s.mkdirAll(dest); err != nil {
+	return b, nil
+}
+
+func (s *Site) writeImage(destP string, b []byte) error {
+	if err := s.mkdirAll(path.Dir(destP)); err != nil {
 		return fmt.Errorf("making directory: %w", err)
 	}
-	destP := path.Join(dest, n)
+	if err := s.checkFilenameLen(destP); err != nil {
+		return err
+	}
 	if err := s.writeFile(destP, b); err != nil {
 		return fmt.Errorf("writing image: %w", err)
 	}
 	return nil
 }
 
+// compressPDF shrinks the pdf, keeping the original if the compressed version is not smaller.
+func (s *Site) compressPDF(data []byte) ([]byte, error) {
+	compressed, err := s.pdfCompressor(data)
+	if err != nil {
+		return nil, fmt.Errorf("compressing pdf: %w", err)
+	}
+	if len(compressed) >= len(data) {
+		return data, nil
+	}
+	return compressed, nil
+}
+
 func (s *Site) addStatic(srcDir, destDir, name string) error {
 	src := path.Join(resources, srcDir, name)
-	dest := path.Join(s.dest, destDir, name)
 	data, err := fs.ReadFile(s.fSys, src)
 	if err != nil {
 		return fmt.Errorf("opening static file: %w", err)
 	}
+	dest := s.fingerprint(path.Join(s.dest, destDir, name), data)
+	if err := s.checkFilenameLen(dest); err != nil {
+		return err
+	}
 	if err := s.writeFile(dest, data); err != nil {
 		return fmt.Errorf("writing static file: %w", err)
 	}
 	return nil
 }
 
+var fingerprintExts = []string{".css", ".png", ".jpg", ".webp", ".gif", ".svg"}
+
+// fingerprint adds the first 8 hex characters of the SHA-256 hash of the data to the name of stylesheets and images,
+// remembering the new path so pages that link to the asset can be rewritten.
+func (s *Site) fingerprint(dest string, data []byte) string {
+	ext := path.Ext(dest)
+	if !s.FingerprintAssets || !slices.Contains(fingerprintExts, ext) {
+		return dest
+	}
+	sum := sha256.Sum256(data)
+	hashed := strings.TrimSuffix(dest, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.assetManifest == nil {
+		s.assetManifest = make(map[string]string)
+	}
+	urlPath := "/" + strings.TrimPrefix(strings.TrimPrefix(dest, s.dest), "/")
+	s.assetManifest[urlPath] = "/" + strings.TrimPrefix(strings.TrimPrefix(hashed, s.dest), "/")
+	return hashed
+}
+
+// rewriteAssetURLs replaces quoted links to assets with links to their fingerprinted versions.
+func (s *Site) rewriteAssetURLs(b []byte) []byte {
+	urlPaths := make([]string, 0, len(s.assetManifest))
+	for urlPath := range s.assetManifest {
+		urlPaths = append(urlPaths, urlPath)
+	}
+	slices.Sort(urlPaths)
+	for _, urlPath := range urlPaths {
+		hashed := s.assetManifest[urlPath]
+		for _, q := range []string{`"`, `'`} {
+			b = bytes.ReplaceAll(b, []byte(q+urlPath+q), []byte(q+hashed+q))
+		}
+	}
+	return b
+}
+
+// Asset is the fingerprinted path of the stylesheet or image, or the path if the asset is not fingerprinted.
+// The path is under the base path of the site.
+func (d Data) Asset(urlPath string) string {
+	if hashed, ok := d.Assets[urlPath]; ok {
+		urlPath = hashed
+	}
+	return strings.TrimSuffix(d.Site.BasePath, "/") + urlPath
+}
+
+// addAppShell writes the header, navigation, and footer of the site around an empty main element that scripts can fill.
+func (s *Site) addAppShell() error {
+	t, err := s.lookupMainTemplate(path.Join(resources, "app-shell.html"))
+	if err != nil {
+		return fmt.Errorf("looking up template: %w", err)
+	}
+	data := Data{
+		Site: *s,
+		Page: Page{
+			Name:   s.Name,
+			Path:   "/app-shell.html",
+			Robots: s.DefaultRobotsTag,
+			Meta:   s.pageMeta(PageMeta{}),
+		},
+		Assets: s.assetManifest,
+	}
+	buf := new(bytes.Buffer)
+	if err := s.executeTemplate(buf, t, data); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	b := mainContentRE.ReplaceAll(buf.Bytes(), []byte(`<main id="app"></main>`))
+	b = s.rewriteAssetURLs(b)
+	b = s.addBuildComment(b)
+	dest := path.Join(s.dest, "app-shell.html")
+	if err := s.writeFile(dest, b); err != nil {
+		return fmt.Errorf("writing app shell: %w", err)
+	}
+	return nil
+}
+
+// addInitJS writes the script that sets up lazy images, smooth scrolling, and back-to-top buttons.
+func (s *Site) addInitJS() error {
+	return s.addStatic("", "", "init.js")
+}
+
+// checkFilenameLen ensures the name of the file is not too long for filesystems that limit name length.
+func (s *Site) checkFilenameLen(dest string) error {
+	name := path.Base(dest)
+	if s.MaxFilenameLen > 0 && len(name) > s.MaxFilenameLen {
+		return fmt.Errorf("filename %q longer than %v bytes", name, s.MaxFilenameLen)
+	}
+	return nil
+}
+
+// limitWrites ensures no generated file the site writes is larger than MaxOutputFileBytes, such as a page from a template that loops forever.
+// Copied binary files are not limited because they are already checked against MaxResourceSize.
+func (s *Site) limitWrites() {
+	if s.MaxOutputFileBytes <= 0 {
+		return
+	}
+	writeFile := s.writeFile
+	s.writeFile = func(name string, data []byte) error {
+		_, binary := binaryFileTypes[strings.ToLower(path.Ext(name))]
+		if !binary && len(data) > s.MaxOutputFileBytes {
+			return fmt.Errorf("%q is %v bytes, larger than the limit of %v bytes", name, len(data), s.MaxOutputFileBytes)
+		}
+		return writeFile(name, data)
+	}
+}
+
 func (s *Site) addPage(pageName, srcDir, srcName string, data interface{}) error {
+	return s.addTitledPage(pageName, "", srcDir, srcName, data)
+}
+
+// addTitledPage adds a page with a browser tab title that is different than its name.
+func (s *Site) addTitledPage(pageName, title, srcDir, srcName string, data interface{}) error {
+	return s.addPageAs(pageName, title, srcDir, srcName, srcName, data)
+}
+
+func (s *Site) addPageAs(pageName, title, srcDir, srcName, destName string, data interface{}) error {
+	return s.addPageWithMeta(pageName, title, srcDir, srcName, destName, PageMeta{}, data)
+}
+
+// addPageWithMeta adds a page with social media meta tags that override the defaults of the site.
+func (s *Site) addPageWithMeta(pageName, title, srcDir, srcName, destName string, meta PageMeta, data interface{}) error {
+	if err := s.trackPageName(pageName, destName); err != nil {
+		return fmt.Errorf("checking page name: %w", err)
+	}
 	p := Page{
-		Name: pageName,
-		Data: data,
+		Name:       pageName,
+		Title:      title,
+		Path:       "/" + destName,
+		Alternates: s.pageAlternates(srcDir, srcName, destName),
+		Robots:     s.DefaultRobotsTag,
+		Canonical:  s.canonicalURL(destName),
+		Meta:       s.pageMeta(meta),
+		Data:       data,
 	}
 	tmplData := Data{
-		Site: *s,
+		Site: s.snapshot(),
 		Page: p,
 	}
-	if err := s.addFile(srcDir, srcName, tmplData); err != nil {
-		return fmt.Errorf("writing file %v, %w", srcName, err)
+	b, err := s.addFile(srcDir, srcName, destName, &tmplData)
+	if err != nil {
+		return fmt.Errorf("writing file %v, %w", destName, err)
 	}
+	s.trackPage(tmplData.Page, b)
 	return nil
 }
 
-func (s *Site) addFile(srcDir, name string, data interface{}) error {
-	if err := s.mkdirAll(s.dest); err != nil {
-		return fmt.Errorf("making directory: %w", err)
+// canonicalURL is the absolute url of the page, or an empty string if the site has no BaseURL.
+// The home page is served at the root of the site.
+func (s *Site) canonicalURL(destName string) string {
+	if len(s.BaseURL) == 0 {
+		return ""
 	}
-	src := path.Join(resources, srcDir, name)
+	if destName == "home.html" {
+		destName = ""
+	}
+	u := s.absURL(destName)
+	if len(destName) == 0 {
+		u = strings.TrimSuffix(u, "/") + "/"
+	}
+	return u
+}
+
+// pageMeta fills in the social media meta tags that are not set with the defaults of the site.
+func (s *Site) pageMeta(m PageMeta) PageMeta {
+	if len(m.OGDescription) == 0 {
+		m.OGDescription = s.Description
+	}
+	if len(m.OGImage) == 0 {
+		m.OGImage = s.DefaultOGImage
+	}
+	if strings.HasPrefix(m.OGImage, "/") {
+		m.OGImage = s.absURL(m.OGImage)
+	}
+	if len(m.TwitterCard) == 0 {
+		m.TwitterCard = "summary"
+		if len(m.OGImage) != 0 {
+			m.TwitterCard = "summary_large_image"
+		}
+	}
+	return m
+}
+
+// snapshot copies the site for executing templates.
+func (s *Site) snapshot() Site {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s
+}
+
+// trackPage remembers the page after it is written.
+func (s *Site) trackPage(p Page, rendered []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages = append(s.pages, p)
+	if s.SearchCorpus {
+		ip := indexedPage{
+			Path:  p.Path,
+			Title: p.Name,
+			HTML:  string(rendered),
+		}
+		s.indexedPages = append(s.indexedPages, ip)
+	}
+}
+
+// translatedName is the name of the file with the translation of the source file, such as home.es.html for home.html.
+func translatedName(srcName, lang string) string {
+	ext := path.Ext(srcName)
+	return strings.TrimSuffix(srcName, ext) + "." + lang + ext
+}
+
+// pageAlternates links to the translations of the page, if any exist.
+func (s *Site) pageAlternates(srcDir, srcName, destName string) []Alternate {
+	var alts []Alternate
+	for _, lang := range s.Languages {
+		src := path.Join(resources, srcDir, translatedName(srcName, lang))
+		if _, err := fs.Stat(s.fSys, src); err != nil {
+			continue
+		}
+		a := Alternate{
+			Lang: lang,
+			Href: "/" + path.Join(lang, destName),
+		}
+		alts = append(alts, a)
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	return append([]Alternate{{"x-default", "/" + destName}}, alts...)
+}
+
+// addTranslatedPage writes the translation of the page to the folder for the language, if the translation exists.
+func (s *Site) addTranslatedPage(pageName, title, lang, srcDir, srcName string, data interface{}) error {
+	translatedSrcName := translatedName(srcName, lang)
+	if _, err := fs.Stat(s.fSys, path.Join(resources, srcDir, translatedSrcName)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("checking for translation: %w", err)
+	}
+	destName := path.Join(lang, srcName)
+	p := Page{
+		Name:       pageName,
+		Title:      title,
+		Path:       "/" + destName,
+		Lang:       lang,
+		Alternates: s.pageAlternates(srcDir, srcName, srcName),
+		Robots:     s.DefaultRobotsTag,
+		Canonical:  s.canonicalURL(destName),
+		Meta:       s.pageMeta(PageMeta{}),
+		Data:       data,
+	}
+	tmplData := Data{
+		Site: s.snapshot(),
+		Page: p,
+	}
+	b, err := s.addFile(srcDir, translatedSrcName, destName, &tmplData)
+	if err != nil {
+		return fmt.Errorf("writing file %v, %w", destName, err)
+	}
+	s.trackPage(tmplData.Page, b)
+	return nil
+}
+
+// trackPageName remembers the first file that uses each page name.
+// Duplicate names are errors for strict sites and warnings otherwise.
+func (s *Site) trackPageName(name, file string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pageNames == nil {
+		s.pageNames = make(map[string]string)
+	}
+	first, ok := s.pageNames[name]
+	if !ok {
+		s.pageNames[name] = file
+		return nil
+	}
+	if s.StrictPageNames {
+		return fmt.Errorf("page name %q used by %v and %v", name, first, file)
+	}
+	s.logger.Printf("warning: page name %q used by %v and %v", name, first, file)
+	return nil
+}
+
+// addFile writes the page, setting its word count and reading time from its content first.
+func (s *Site) addFile(srcDir, srcName, destName string, data *Data) ([]byte, error) {
+	dest := path.Join(s.dest, destName)
+	if err := s.checkFilenameLen(dest); err != nil {
+		return nil, err
+	}
+	if err := s.mkdirAll(path.Dir(dest)); err != nil {
+		return nil, fmt.Errorf("making directory: %w", err)
+	}
+	src := path.Join(resources, srcDir, srcName)
 	t, err := s.lookupMainTemplate(src)
 	if err != nil {
-		return fmt.Errorf("looking up template: %w", err)
+		return nil, fmt.Errorf("looking up template: %w", err)
 	}
+	if content := t.Lookup("content"); content != nil {
+		buf := new(bytes.Buffer)
+		if err := s.executeTemplate(buf, content, data.Page.Data); err != nil {
+			return nil, fmt.Errorf("executing content template: %w", err)
+		}
+		data.Page.WordCount = s.wordCount(buf.Bytes())
+		data.Page.ReadingTime = s.readingTime(data.Page.WordCount)
+	}
+	data.Assets = s.assetManifest
 	buf := new(bytes.Buffer)
-	if err := s.executeTemplate(buf, t, data); err != nil {
-		return fmt.Errorf("executing template: %w", err)
+	if err := s.executeTemplate(buf, t, *data); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+	b := s.rewriteAssetURLs(buf.Bytes())
+	b = s.addBuildComment(b)
+	if s.StrictA11y {
+		if err := s.checkLandmarks(b, destName); err != nil {
+			return nil, err
+		}
 	}
-	b := buf.Bytes()
-	dest := path.Join(s.dest, name)
 	if err := s.writeFile(dest, b); err != nil {
-		return fmt.Errorf("writing template: %w", err)
+		return nil, fmt.Errorf("writing template: %w", err)
 	}
-	return nil
+	return b, nil
+}
+
+// addBuildComment adds a comment with the build time and version to the end of the page, if enabled.
+func (s *Site) addBuildComment(page []byte) []byte {
+	if !s.BuildComment || s.BuildTime.IsZero() || len(s.Version) == 0 {
+		return page
+	}
+	comment := fmt.Sprintf("\n<!-- build: time=%v version=%v -->", s.BuildTime.UTC().Format(time.RFC3339), s.Version)
+	return append(page, comment...)
 }
 
 func (s *Site) lookupMainTemplate(content string) (*template.Template, error) {
-	patterns := []string{
-		path.Join(resources, "main.html"),
-		path.Join(resources, "index.css"),
-		path.Join(resources, "nav.html"),
-		path.Join(resources, "nav.css"),
-		content,
-	}
-	t := s.newTemplate("main.html")
-	if _, err := t.ParseFS(s.fSys, patterns...); err != nil {
+	t, err := s.cloneBaseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.ParseFS(s.fSys, content); err != nil {
 		return nil, fmt.Errorf("parsing template filesystem: %w", err)
 	}
 	return t, nil
 }
 
-func (*Site) newTemplate(tmplName string) *template.Template {
+// cloneBaseTemplate copies the main template, which is parsed the first time it is needed, so a page can add its content.
+func (s *Site) cloneBaseTemplate() (*template.Template, error) {
+	s.baseTemplateOnce.Do(func() {
+		patterns := []string{
+			path.Join(resources, "main.html"),
+			path.Join(resources, "index.css"),
+			path.Join(resources, "nav.html"),
+			path.Join(resources, "nav.css"),
+		}
+		t := s.newTemplate("main.html")
+		_, err := t.ParseFS(s.fSys, patterns...)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.baseTemplate, s.baseTemplateErr = t, err
+	})
+	if s.baseTemplateErr != nil {
+		return nil, fmt.Errorf("parsing main template filesystem: %w", s.baseTemplateErr)
+	}
+	return template.Must(s.baseTemplate.Clone()), nil
+}
+
+func (s *Site) newTemplate(tmplName string) *template.Template {
 	t := template.New(tmplName)
 	t.Option("missingkey=error")
+	t.Funcs(s.templateFuncs())
 	return t
 }
 
+func (s *Site) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"shareURL":     s.socialShareURL,
+		"formatDate":   formatDate,
+		"truncateText": truncateText,
+		"safeURL":      safeURL,
+		"url":          s.siteURL,
+	}
+}
+
+// siteURL puts root-relative urls, such as /sign-up.html, under the base path of the site.
+// Other urls are not changed.
+func (s *Site) siteURL(u string) string {
+	if !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") {
+		return u
+	}
+	return s.urlPath(u)
+}
+
+var linkAttrRE = regexp.MustCompile(`(\s(?:href|src)=)("[^"]*"|'[^']*')`)
+
+// prefixBasePath puts the root-relative href and src attributes of the html, such as those of event files, under the base path of the site.
+func (s *Site) prefixBasePath(data []byte) []byte {
+	if len(s.BasePath) == 0 || s.BasePath == "/" {
+		return data
+	}
+	return linkAttrRE.ReplaceAllFunc(data, func(attr []byte) []byte {
+		m := linkAttrRE.FindSubmatch(attr)
+		name, quoted := m[1], m[2]
+		q := quoted[0]
+		u := s.siteURL(string(quoted[1 : len(quoted)-1]))
+		return []byte(fmt.Sprintf("%s%c%v%c", name, q, u, q))
+	})
+}
+
+// formatDate formats the time with the layout, such as "January 2, 2006".
+func formatDate(t time.Time, layout string) string {
+	return t.Format(layout)
+}
+
+// truncateText shortens the text to at most maxBytes without splitting a character.
+func truncateText(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	if maxBytes <= 0 {
+		return ""
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
+// safeURL returns the url if it is relative or has a scheme that cannot run scripts, otherwise an empty string.
+func safeURL(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto", "tel":
+		return u.String()
+	default:
+		return ""
+	}
+}
+
+// socialShareURL creates a link to share the page on the platform, or an empty string if the platform is unknown.
+func (*Site) socialShareURL(platform, pageURL, title string) string {
+	var shareURL string
+	q := make(url.Values)
+	switch platform {
+	case "twitter":
+		shareURL = "https://twitter.com/intent/tweet"
+		q.Set("text", title)
+		q.Set("url", pageURL)
+	case "facebook":
+		shareURL = "https://www.facebook.com/sharer/sharer.php"
+		q.Set("u", pageURL)
+	case "linkedin":
+		shareURL = "https://www.linkedin.com/sharing/share-offsite/"
+		q.Set("url", pageURL)
+	default:
+		return ""
+	}
+	return shareURL + "?" + q.Encode()
+}
+
 func (*Site) executeTemplate(w io.Writer, t *template.Template, data interface{}) error {
 	sb := new(strings.Builder)
 	if err := t.Execute(sb, data); err != nil {
@@ -225,12 +945,70 @@ func (*Site) executeTemplate(w io.Writer, t *template.Template, data interface{}
 	return nil
 }
 
+// addEvents writes the future and past events.
+// The errors for all broken event files are returned in a MultiError.
 func (s *Site) addEvents() error {
+	if err := s.validateEventFiles(); err != nil {
+		return wrapErrors("validating event files", err)
+	}
+	if s.RequireNonEmptyDirs {
+		if err := s.checkNonEmptyEventDirs(); err != nil {
+			return fmt.Errorf("checking event directories: %w", err)
+		}
+	}
+	var errs MultiError
 	if err := s.addFutureEvents(); err != nil {
-		return fmt.Errorf("adding future events: %w", err)
+		errs.add(wrapErrors("adding future events", err))
 	}
 	if err := s.addPastEvents(); err != nil {
-		return fmt.Errorf("adding past events: %w", err)
+		errs.add(wrapErrors("adding past events", err))
+	}
+	if err := errs.errorOrNil(); err != nil {
+		return err
+	}
+	if err := s.addFeed(); err != nil {
+		return fmt.Errorf("adding events feed: %w", err)
+	}
+	return nil
+}
+
+// eventDirs lists the folder of future events and the folders of past events for each year.
+func (s *Site) eventDirs() ([]string, error) {
+	dirs := []string{path.Join(resources, events, "future")}
+	pastDir := path.Join(resources, events, "past")
+	yearEntries, err := fs.ReadDir(s.fSys, pastDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading past events: %w", err)
+	}
+	for _, y := range yearEntries {
+		if y.IsDir() {
+			dirs = append(dirs, path.Join(pastDir, y.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// checkNonEmptyEventDirs ensures the future and past year event directories each have an event.
+func (s *Site) checkNonEmptyEventDirs() error {
+	dirs, err := s.eventDirs()
+	if err != nil {
+		return err
+	}
+	var emptyDirs []string
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(s.fSys, dir)
+		if err != nil {
+			return fmt.Errorf("reading event directory: %w", err)
+		}
+		hasEvent := slices.ContainsFunc(entries, func(de fs.DirEntry) bool {
+			return !de.IsDir() && path.Ext(de.Name()) == ".html"
+		})
+		if !hasEvent {
+			emptyDirs = append(emptyDirs, dir)
+		}
+	}
+	if len(emptyDirs) != 0 {
+		return fmt.Errorf("event directories without events: %v", strings.Join(emptyDirs, ", "))
 	}
 	return nil
 }
@@ -251,11 +1029,24 @@ func (s *Site) addFutureEvents() error {
 	futureEntry := eventEntries[idx]
 	e, err := s.createEventGroup(eventsDir, futureEntry)
 	if err != nil {
-		return fmt.Errorf("adding future events folder: %w", err)
+		return wrapErrors("adding future events folder", err)
+	}
+	if n := len(e.Entries); s.MaxFutureEvents > 0 && n > s.MaxFutureEvents {
+		s.logger.Printf("warning: only showing %v of %v future events", s.MaxFutureEvents, n)
+		e.truncate(s.MaxFutureEvents)
 	}
+	s.futureEvents = e
 	if err := s.addPage("Upcoming Speakers", events, "future-events.html", e); err != nil {
 		return fmt.Errorf("adding future events page: %w", err)
 	}
+	if s.GenerateEmailPages {
+		if err := s.addEmailPage("Upcoming Speakers", events, "future-events.html", e); err != nil {
+			return fmt.Errorf("adding future events email page: %w", err)
+		}
+		if err := s.addReminderEmailTemplates(e); err != nil {
+			return fmt.Errorf("adding reminder emails: %w", err)
+		}
+	}
 	return err
 }
 
@@ -265,26 +1056,186 @@ func (s *Site) addPastEvents() error {
 	if err != nil {
 		return fmt.Errorf("reading past events: %w", err)
 	}
-	slices.Reverse(yearEntries)
-	yrs := make([]EventGroup, 0, len(yearEntries))
-	for _, y := range yearEntries {
-		yr, err := s.createEventGroup(eventsDir, y)
-		if err != nil {
-			return fmt.Errorf("adding events for year %v: %w", y.Name(), err)
+	yrs, err := s.createEventGroups(eventsDir, yearEntries)
+	if err != nil {
+		return err
+	}
+	s.pastEvents = yrs
+	if s.Verbose {
+		for _, c := range s.auditEventDateConflicts(yrs) {
+			s.logger.Printf("warning: events on the same date: %v", c)
 		}
-		yrs = append(yrs, *yr)
 	}
-	if err := s.addPage("Past Events", events, "past-events.html", yrs); err != nil {
+	if err := s.addPastEventsPages(yrs); err != nil {
 		return fmt.Errorf("adding past events page: %w", err)
 	}
+	if err := s.addResourcesTOC(yrs); err != nil {
+		return fmt.Errorf("adding resources table of contents: %w", err)
+	}
+	if err := s.addKeywordCloud(yrs); err != nil {
+		return fmt.Errorf("adding keyword cloud: %w", err)
+	}
+	if err := s.addSpeakerFeeds(speakerEvents(yrs)); err != nil {
+		return fmt.Errorf("adding speaker feeds: %w", err)
+	}
+	if err := s.addResourceFeed(yrs); err != nil {
+		return fmt.Errorf("adding resource feed: %w", err)
+	}
+	if s.GenerateGallery {
+		if err := s.addGalleryPage(yrs); err != nil {
+			return fmt.Errorf("adding gallery page: %w", err)
+		}
+	}
 	if s.OneResource {
-		if err := s.addPage("Videos & Resources", events, "videos-and-resources.html", yrs); err != nil {
+		if err := s.addTitledPage("Videos & Resources", "Event Videos & Resources | "+s.Name, events, "videos-and-resources.html", yrs); err != nil {
 			return fmt.Errorf("adding past events resources: %w", err)
 		}
 	}
 	return nil
 }
 
+// addPastEventsPages writes the past events, splitting the years into pages of PastEventsPageSize years if it is set.
+func (s *Site) addPastEventsPages(yrs []EventGroup) error {
+	pageSize := len(yrs)
+	if s.PastEventsPageSize > 0 {
+		pageSize = s.PastEventsPageSize
+	}
+	totalPages := 1
+	if pageSize > 0 {
+		totalPages = max((len(yrs)+pageSize-1)/pageSize, 1)
+	}
+	for i := 0; i < totalPages; i++ {
+		pageNumber := i + 1
+		start, end := i*pageSize, min((i+1)*pageSize, len(yrs))
+		data := PaginatedEventData{
+			PastEvents: PastEvents{
+				Years:        yrs[start:end],
+				Microformats: s.Microformats,
+			},
+			PageNumber: pageNumber,
+			TotalPages: totalPages,
+		}
+		if pageNumber > 1 {
+			data.PrevPath = "/" + pastEventsPageName(pageNumber-1)
+		}
+		if pageNumber < totalPages {
+			data.NextPath = "/" + pastEventsPageName(pageNumber+1)
+		}
+		pageName := "Past Events"
+		if pageNumber > 1 {
+			pageName = fmt.Sprintf("Past Events (Page %v)", pageNumber)
+		}
+		if err := s.addPageAs(pageName, "", events, "past-events.html", pastEventsPageName(pageNumber), data); err != nil {
+			return fmt.Errorf("adding page %v: %w", pageNumber, err)
+		}
+	}
+	return nil
+}
+
+// pastEventsPageName is the name of the file of the page of past events, such as past-events-2.html.
+func pastEventsPageName(pageNumber int) string {
+	if pageNumber <= 1 {
+		return "past-events.html"
+	}
+	return fmt.Sprintf("past-events-%v.html", pageNumber)
+}
+
+// pastEventsYearPath links to the year on the page of past events that shows it.
+func (s *Site) pastEventsYearPath(year string) string {
+	pageNumber := 1
+	if s.PastEventsPageSize > 0 {
+		if i := slices.IndexFunc(s.pastEvents, func(eg EventGroup) bool { return eg.Year == year }); i >= 0 {
+			pageNumber = i/s.PastEventsPageSize + 1
+		}
+	}
+	return "/" + pastEventsPageName(pageNumber) + "#year-" + year
+}
+
+// auditEventDateConflicts describes the dates that have more than one event, which are usually typos in file names.
+func (*Site) auditEventDateConflicts(yrs []EventGroup) []string {
+	m := make(map[time.Time][]string)
+	for _, eg := range yrs {
+		for _, e := range eg.Entries {
+			if e.Date.IsZero() {
+				continue
+			}
+			m[e.Date] = append(m[e.Date], path.Join(eg.Year, e.File))
+		}
+	}
+	var dates []time.Time
+	for d, files := range m {
+		if len(files) > 1 {
+			dates = append(dates, d)
+		}
+	}
+	slices.SortFunc(dates, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+	conflicts := make([]string, len(dates))
+	for i, d := range dates {
+		conflicts[i] = d.Format("2006-01-02") + ": " + strings.Join(m[d], ", ")
+	}
+	return conflicts
+}
+
+// addGalleryPage writes a page with the photos of each year of events.
+func (s *Site) addGalleryPage(yrs []EventGroup) error {
+	var images []GalleryImage
+	for _, eg := range yrs {
+		for _, src := range eg.Images {
+			img := GalleryImage{
+				Src:  src,
+				Alt:  "photo of " + eventTitle(path.Base(src)),
+				Year: eg.Year,
+			}
+			images = append(images, img)
+		}
+	}
+	return s.addPage("Gallery", events, "gallery.html", images)
+}
+
+// createEventGroups adds the events for each year folder, adding up to Concurrency years at a time.
+// The groups are ordered by year, newest first.
+func (s *Site) createEventGroups(dir string, yearEntries []fs.DirEntry) ([]EventGroup, error) {
+	sem := make(chan struct{}, max(s.Concurrency, 1))
+	errs := make(chan error, len(yearEntries))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	yrs := make([]EventGroup, 0, len(yearEntries))
+	for _, y := range yearEntries {
+		wg.Add(1)
+		go func(y fs.DirEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			yr, err := s.createEventGroup(dir, y)
+			if err != nil {
+				errs <- wrapErrors("adding events for year "+y.Name(), err)
+				return
+			}
+			mu.Lock()
+			yrs = append(yrs, *yr)
+			mu.Unlock()
+		}(y)
+	}
+	wg.Wait()
+	close(errs)
+	var allErrs MultiError
+	for err := range errs {
+		allErrs.add(err)
+	}
+	if len(allErrs) != 0 {
+		slices.SortFunc(allErrs, func(a, b error) int {
+			return strings.Compare(a.Error(), b.Error())
+		})
+		return nil, allErrs.errorOrNil()
+	}
+	slices.SortFunc(yrs, func(a, b EventGroup) int {
+		return strings.Compare(b.Year, a.Year)
+	})
+	return yrs, nil
+}
+
 func (s *Site) createEventGroup(dir string, f fs.DirEntry) (*EventGroup, error) {
 	folderName := f.Name()
 	if !f.IsDir() {
@@ -295,14 +1246,23 @@ func (s *Site) createEventGroup(dir string, f fs.DirEntry) (*EventGroup, error)
 	if err != nil {
 		return nil, fmt.Errorf("reading folder: %w", err)
 	}
-	slices.Reverse(orderedFiles)
+	if err := s.sortEventFiles(orderedFiles); err != nil {
+		return nil, fmt.Errorf("sorting files: %w", err)
+	}
 	eg := new(EventGroup)
 	eg.Year = folderName
+	var errs MultiError
 	for _, ff := range orderedFiles {
 		if err := s.addEventFile(eg, root, folderName, ff); err != nil {
-			return nil, fmt.Errorf("adding file to event group: %w", err)
+			errs.add(fmt.Errorf("adding file to event group: %w", err))
 		}
 	}
+	if err := errs.errorOrNil(); err != nil {
+		return nil, err
+	}
+	if errs := s.auditResourceLinks(eg); len(errs) != 0 {
+		return nil, fmt.Errorf("auditing resource links: %w", errors.Join(errs...))
+	}
 	return eg, nil
 }
 
@@ -313,16 +1273,56 @@ func (s *Site) addEventFile(eg *EventGroup, dir, year string, ff fs.DirEntry) er
 		if err := s.addEvent(eg, dir, nn, year); err != nil {
 			return fmt.Errorf("adding event: %w", err)
 		}
-	case ".jpg":
+	case ".jpg", ".webp", ".gif":
 		destDir := path.Join("images", events, year)
-		if err := s.addImage(ff, dir, destDir, kB50); err != nil {
+		p, err := s.addBinaryFile(ff, dir, destDir, kB50)
+		if err != nil {
 			return fmt.Errorf("adding resource: %w", err)
 		}
+		eg.Images = append(eg.Images, p)
 	case ".pdf", ".docx", ".xlsx":
 		destDir := path.Join("resources", "events", year)
-		if err := s.addImage(ff, dir, destDir, mB10); err != nil {
+		p, err := s.addBinaryFile(ff, dir, destDir, s.MaxResourceSize)
+		if err != nil {
 			return fmt.Errorf("adding resource: %w", err)
 		}
+		info, err := ff.Info()
+		if err != nil {
+			return fmt.Errorf("getting resource info: %w", err)
+		}
+		rf := ResourceFile{
+			Path:    p,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		eg.Files = append(eg.Files, rf)
+		docs := &eg.Documents
+		if s.OneResource {
+			docs = &eg.Resources
+		}
+		writeResourceLink(docs, s.urlPath(p), resourceLabel(nn))
+	case ".mp3", ".m4a", ".wav":
+		destDir := path.Join("resources", "events", year)
+		if _, err := s.addBinaryFile(ff, dir, destDir, s.MaxResourceSize); err != nil {
+			return fmt.Errorf("adding audio resource: %w", err)
+		}
+		audioFile := path.Join(dir, nn)
+		transcriptFile, ok := s.findSibling(audioFile, transcriptExts)
+		if !ok {
+			break
+		}
+		if err := s.addAudioTranscriptPage(audioFile, transcriptFile, eg); err != nil {
+			return fmt.Errorf("adding audio transcript: %w", err)
+		}
+	case ".url":
+		if err := s.addExternalResource(eg, dir, nn); err != nil {
+			return fmt.Errorf("adding external resource: %w", err)
+		}
+	case ".txt", ".vtt":
+		transcriptFile := path.Join(dir, nn)
+		if _, ok := s.findSibling(transcriptFile, audioExts); !ok {
+			s.logger.Printf("warning: transcript %v has no audio file", transcriptFile)
+		}
 	default:
 		// this check is mostly for audit purposes
 		// usually, add the extension to the list above
@@ -331,12 +1331,78 @@ func (s *Site) addEventFile(eg *EventGroup, dir, year string, ff fs.DirEntry) er
 	return nil
 }
 
+// addExternalResource links to the url in the .url file, which is hosted elsewhere, such as a video.
+func (s *Site) addExternalResource(eg *EventGroup, dir, urlFileName string) error {
+	src := path.Join(dir, urlFileName)
+	data, err := fs.ReadFile(s.fSys, src)
+	if err != nil {
+		return fmt.Errorf("reading url file: %w", err)
+	}
+	rawURL := strings.TrimSpace(string(s.stripBOM(data)))
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parsing url in %v: %w", src, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url in %v must be http or https, got %q", src, rawURL)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("url in %v has no host: %q", src, rawURL)
+	}
+	writeResourceLink(&eg.Resources, u.String(), resourceLabel(urlFileName))
+	return nil
+}
+
+// resourceLabel is the name of the resource file without the extension or order number and with hyphens and underscores replaced by spaces.
+func resourceLabel(fileName string) string {
+	label := strings.TrimSuffix(fileName, path.Ext(fileName))
+	if _, ok := eventOrder(label); ok {
+		_, label, _ = strings.Cut(label, "_")
+	}
+	return strings.NewReplacer("-", " ", "_", " ").Replace(label)
+}
+
+// writeResourceLink adds a link to the resource.
+func writeResourceLink(buf *bytes.Buffer, href, label string) {
+	fmt.Fprintf(buf, "<a href=\"%v\">%v</a>\n", html.EscapeString(href), html.EscapeString(label))
+}
+
 func (s *Site) addEvent(eg *EventGroup, dir, eventHtmlName, year string) error {
 	src := path.Join(dir, eventHtmlName)
 	data, err := fs.ReadFile(s.fSys, src)
 	if err != nil {
 		return fmt.Errorf("reading event file: %w", err)
 	}
+	data = s.stripBOM(data)
+	if err := s.validateUTF8(data, src); err != nil {
+		return err
+	}
+	t, err := s.parseEventTemplate(src, data)
+	if err != nil {
+		return err
+	}
+	meta, err := parseEventMeta(data)
+	if err != nil {
+		return fmt.Errorf("parsing event meta of %v: %w", src, err)
+	}
+	if err := s.checkDeprecatedBlocks(src, t); err != nil {
+		return err
+	}
+	e := EventEntry{
+		File:    eventHtmlName,
+		Title:   meta.Name,
+		Speaker: meta.Speaker,
+		Date:    eventDate(eventHtmlName),
+	}
+	if len(meta.StartDate) != 0 {
+		e.StartDate, _ = parseEventStartDate(meta.StartDate) // validated when the meta was parsed
+	}
+	if len(e.Title) == 0 {
+		e.Title = eventTitle(eventHtmlName)
+	}
+	if len(e.Speaker) == 0 {
+		e.Speaker = eventTitle(eventHtmlName)
+	}
 	parts := []struct {
 		tmplName string
 		buf      *bytes.Buffer
@@ -351,34 +1417,276 @@ func (s *Site) addEvent(eg *EventGroup, dir, eventHtmlName, year string) error {
 		},
 	}
 	for _, p := range parts {
-		t := s.newTemplate("")
-		if _, err := t.Parse(string(data)); err != nil {
-			return fmt.Errorf("parsing event file: %w", err)
-		}
-		t = t.Lookup(p.tmplName)
-		if t == nil {
+		pt := t.Lookup(p.tmplName)
+		if pt == nil {
 			return fmt.Errorf("no template named %q in %v", p.tmplName, src)
 		}
 		beforeLen := p.buf.Len()
-		if err := s.executeTemplate(p.buf, t, nil); err != nil {
+		var out bytes.Buffer
+		if err := s.executeTemplate(&out, pt, nil); err != nil {
 			return fmt.Errorf("executing template: %w", err)
 		}
+		p.buf.Write(s.prefixBasePath(out.Bytes()))
 		afterLen := p.buf.Len()
+		switch p.tmplName {
+		case "event":
+			e.Content = string(p.buf.Bytes()[beforeLen:afterLen])
+		case "resources":
+			e.Resources = string(p.buf.Bytes()[beforeLen:afterLen])
+		}
 		if p.tmplName == "resources" && beforeLen != afterLen && !s.OneResource {
-			if err := s.addResourcesLink(year, eventHtmlName, &eg.Events, p.buf); err != nil {
+			if err := s.addResourcesLink(year, eventHtmlName, e.meta(*meta), &eg.Events, p.buf); err != nil {
 				return fmt.Errorf("adding resources link: %w", err)
 			}
+			e.ResourcesHref = path.Join(resources, events, year, eventHtmlName)
+		}
+	}
+	e.eventsEnd = eg.Events.Len()
+	e.resourcesEnd = eg.Resources.Len()
+	eg.Entries = append(eg.Entries, e)
+	if s.PlainTextEvents {
+		if err := s.addPlainTextEvent(eg, year, eventHtmlName); err != nil {
+			return fmt.Errorf("adding plain text event: %w", err)
 		}
 	}
 	return nil
 }
 
-func (s *Site) addResourcesLink(year, eventHtmlName string, eventBuf, resourcesBuf *bytes.Buffer) error {
+// meta is the frontmatter of the event with the title, speaker, and date of the entry filled in if they are not set.
+// The date is only the month if the name of the event file does not have the day.
+func (e EventEntry) meta(m EventMeta) EventMeta {
+	m.Name = e.Title
+	m.Speaker = e.Speaker
+	if len(m.StartDate) == 0 && !e.Date.IsZero() {
+		layout := "2006-01"
+		if _, n, ok := parseEventDatePrefix(e.File); ok && n == len(time.DateOnly) {
+			layout = time.DateOnly
+		}
+		m.StartDate = e.Date.Format(layout)
+	}
+	return m
+}
+
+// utf8BOM is the byte order mark that some editors add to the start of UTF-8 files.
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// MultiError is a list of errors that are reported together, such as the errors for each broken event file.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// add appends the error, or each error if it is a MultiError.
+func (m *MultiError) add(err error) {
+	if errs, ok := err.(MultiError); ok {
+		*m = append(*m, errs...)
+		return
+	}
+	*m = append(*m, err)
+}
+
+// errorOrNil is nil if there are no errors, the error if there is only one, or the list.
+func (m MultiError) errorOrNil() error {
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+	return m
+}
+
+// wrapErrors prefixes the error, or each error if it is a MultiError so they stay separate.
+func wrapErrors(prefix string, err error) error {
+	errs, ok := err.(MultiError)
+	if !ok {
+		return fmt.Errorf("%v: %w", prefix, err)
+	}
+	wrapped := make(MultiError, len(errs))
+	for i, err := range errs {
+		wrapped[i] = fmt.Errorf("%v: %w", prefix, err)
+	}
+	return wrapped
+}
+
+// eventTemplateNames are the templates that each event file must define.
+var eventTemplateNames = []string{"event", "resources"}
+
+// EventTemplateError is an event file that does not define all of the event templates.
+type EventTemplateError struct {
+	File    string
+	Missing []string
+}
+
+func (e *EventTemplateError) Error() string {
+	return fmt.Sprintf("event file %v does not define templates: %q", e.File, e.Missing)
+}
+
+// parseEventTemplate parses the event file, checking that it defines the templates that are rendered for it.
+func (s *Site) parseEventTemplate(src string, data []byte) (*template.Template, error) {
+	t := s.newTemplate("")
+	if _, err := t.Parse(string(data)); err != nil {
+		return nil, fmt.Errorf("parsing event file %v: %w", src, err)
+	}
+	var missing []string
+	for _, name := range eventTemplateNames {
+		if t.Lookup(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, &EventTemplateError{
+			File:    src,
+			Missing: missing,
+		}
+	}
+	return t, nil
+}
+
+// validateEventFiles checks the templates of all event files before any are rendered, reporting all invalid files together.
+func (s *Site) validateEventFiles() error {
+	dirs, err := s.eventDirs()
+	if err != nil {
+		return err
+	}
+	var errs MultiError
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(s.fSys, dir)
+		if err != nil {
+			return fmt.Errorf("reading event directory: %w", err)
+		}
+		for _, de := range entries {
+			if de.IsDir() || path.Ext(de.Name()) != ".html" {
+				continue
+			}
+			src := path.Join(dir, de.Name())
+			data, err := fs.ReadFile(s.fSys, src)
+			if err != nil {
+				return fmt.Errorf("reading event file: %w", err)
+			}
+			data = s.stripBOM(data)
+			if err := s.validateUTF8(data, src); err != nil {
+				errs.add(err)
+				continue
+			}
+			if _, err := s.parseEventTemplate(src, data); err != nil {
+				errs.add(err)
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// stripBOM removes the UTF-8 byte order mark from the start of the data.
+func (*Site) stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// validateUTF8 ensures the file is UTF-8, which is the charset of the pages.
+func (*Site) validateUTF8(data []byte, name string) error {
+	if !utf8.Valid(data) {
+		return fmt.Errorf("%v is not valid UTF-8", name)
+	}
+	return nil
+}
+
+var eventResourceHrefRE = regexp.MustCompile(`\bhref="/?resources/events/([^/"]+)/([^"#?]+)"`)
+
+// auditResourceLinks ensures the files that the resources of events link to exist.
+func (s *Site) auditResourceLinks(eg *EventGroup) []error {
+	var errs []error
+	for _, e := range eg.Entries {
+		for _, m := range eventResourceHrefRE.FindAllStringSubmatch(e.Resources, -1) {
+			year, name := m[1], m[2]
+			src := path.Join(resources, events, "past", year, name)
+			if year == "future" {
+				src = path.Join(resources, events, year, name)
+			}
+			if _, err := fs.Stat(s.fSys, src); err != nil {
+				err = fmt.Errorf("%v links to missing file %v: %w", e.File, src, err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// checkDeprecatedBlocks ensures the parsed event file does not define templates that are no longer used.
+func (s *Site) checkDeprecatedBlocks(src string, t *template.Template) error {
+	if len(s.DeprecatedTemplateBlocks) == 0 {
+		return nil
+	}
+	var found []string
+	for _, name := range s.DeprecatedTemplateBlocks {
+		if t.Lookup(name) != nil {
+			found = append(found, name)
+		}
+	}
+	if len(found) != 0 {
+		return fmt.Errorf("%v defines deprecated templates: %v", src, strings.Join(found, ", "))
+	}
+	return nil
+}
+
+// truncate keeps the n most recent events of the group, sorted by date, most recent first.
+// Events with the same date keep their order.  Events without dates are the oldest.
+func (eg *EventGroup) truncate(n int) {
+	if n >= len(eg.Entries) {
+		return
+	}
+	type part struct {
+		entry     EventEntry
+		events    []byte
+		resources []byte
+	}
+	parts := make([]part, len(eg.Entries))
+	var eventsStart, resourcesStart int
+	for i, e := range eg.Entries {
+		parts[i] = part{
+			entry:     e,
+			events:    slices.Clone(eg.Events.Bytes()[eventsStart:e.eventsEnd]),
+			resources: slices.Clone(eg.Resources.Bytes()[resourcesStart:e.resourcesEnd]),
+		}
+		eventsStart, resourcesStart = e.eventsEnd, e.resourcesEnd
+	}
+	slices.SortStableFunc(parts, func(a, b part) int {
+		return b.entry.date().Compare(a.entry.date())
+	})
+	eg.Events.Reset()
+	eg.Resources.Reset()
+	eg.Entries = eg.Entries[:0]
+	for _, p := range parts[:n] {
+		eg.Events.Write(p.events)
+		eg.Resources.Write(p.resources)
+		p.entry.eventsEnd = eg.Events.Len()
+		p.entry.resourcesEnd = eg.Resources.Len()
+		eg.Entries = append(eg.Entries, p.entry)
+	}
+}
+
+// date is when the event starts, from its frontmatter or the name of its file.
+func (e EventEntry) date() time.Time {
+	if !e.StartDate.IsZero() {
+		return e.StartDate
+	}
+	return e.Date
+}
+
+func (s *Site) addResourcesLink(year, eventHtmlName string, meta EventMeta, eventBuf, resourcesBuf *bytes.Buffer) error {
 	dest := path.Join(resources, events, year)
 	destP := path.Join(s.dest, dest)
 	resourceName := path.Join(destP, eventHtmlName)
 	linkHref := path.Join(dest, eventHtmlName)
-	if err := s.addEventResourcesPage(destP, resourceName, resourcesBuf); err != nil {
+	if err := s.addEventResourcesPage(destP, resourceName, meta, resourcesBuf); err != nil {
 		return fmt.Errorf("adding event resources page: %w", err)
 	}
 	if err := s.addEventResourcesLink(linkHref, eventBuf); err != nil {
@@ -387,31 +1695,32 @@ func (s *Site) addResourcesLink(year, eventHtmlName string, eventBuf, resourcesB
 	return nil
 }
 
-func (s *Site) addEventResourcesPage(destP, resourceName string, resourcesBuf *bytes.Buffer) error {
+// addEventResourcesPage writes the resources of the event with its structured data.
+func (s *Site) addEventResourcesPage(destP, resourceName string, meta EventMeta, resourcesBuf *bytes.Buffer) error {
 	if err := s.mkdirAll(destP); err != nil {
 		return fmt.Errorf("making directory: %w", err)
 	}
-	// t, err := s.lookupMainTemplate("")
-	// if err != nil {
-	// 	return fmt.Errorf("looking event resources template: %w", err)
-	// }
-	// TODO: this next chunk is a copy from lookupMainTemplate().  fix the duplication
-	patterns := []string{
-		path.Join(resources, "main.html"),
-		path.Join(resources, "index.css"),
-		path.Join(resources, "nav.html"),
-		path.Join(resources, "nav.css"),
+	// event files are written for pages at the root of the site
+	if err := s.rebaseImagePaths(resourcesBuf, s.urlPath("/")); err != nil {
+		return fmt.Errorf("rebasing image paths: %w", err)
 	}
-	t := s.newTemplate("main.html")
-	if _, err := t.ParseFS(s.fSys, patterns...); err != nil {
-		return fmt.Errorf("parsing main template filesystem: %w", err)
+	t, err := s.cloneBaseTemplate()
+	if err != nil {
+		return err
 	}
 
+	jsonLD, err := s.eventJSONLD(meta)
+	if err != nil {
+		return err
+	}
 	content := new(bytes.Buffer)
 	content.WriteString(`{{define "content"}}`)
+	// the json is the data of the template so braces in it are not parsed as actions
+	content.WriteString(`<script type="application/ld+json">{{.}}</script>`)
 	resourcesBuf.WriteTo(content)
 	content.WriteString(`<div class="left">`)
-	content.WriteString(`<a href="javascript:history.back()">back</a>`)
+	// a link to a page rather than a javascript url, which the content security policy blocks
+	fmt.Fprintf(content, `<a href="%v">back to past events</a>`, template.HTMLEscapeString(s.urlPath("past-events.html")))
 	content.WriteString(`</div>`)
 	content.WriteString(`{{end}}`)
 	contentTmpl := content.String()
@@ -422,22 +1731,56 @@ func (s *Site) addEventResourcesPage(destP, resourceName string, resourcesBuf *b
 	buf2 := new(bytes.Buffer)
 	// TODO: this is similar to Site.addPage()
 	p := Page{
-		Name: "Videos/Resources for Event",
+		Name:   "Videos/Resources for Event",
+		Robots: "noindex, follow", // the events pages link to the resources
+		Meta:   s.pageMeta(PageMeta{OGDescription: meta.Description}),
+		Data:   string(jsonLD),
 	}
 	tmplData := Data{
-		Site: *s,
-		Page: p,
+		Site:   s.snapshot(),
+		Page:   p,
+		Assets: s.assetManifest,
 	}
 	if err := s.executeTemplate(buf2, t, tmplData); err != nil {
 		return fmt.Errorf("writing resources info template: %w", err)
 	}
-	data := buf2.Bytes()
+	data := s.rewriteAssetURLs(buf2.Bytes())
+	data = s.addBuildComment(data)
 	if err := s.writeFile(resourceName, data); err != nil {
 		return fmt.Errorf("writing resources file for event: %w", err)
 	}
 	return nil
 }
 
+var srcAttrRE = regexp.MustCompile(`(\ssrc=)("[^"]*"|'[^']*')`)
+
+// rebaseImagePaths makes relative src attributes absolute by resolving them from the base path.
+func (*Site) rebaseImagePaths(buf *bytes.Buffer, basePath string) error {
+	var err error
+	rebased := srcAttrRE.ReplaceAllFunc(buf.Bytes(), func(attr []byte) []byte {
+		m := srcAttrRE.FindSubmatch(attr)
+		name, quoted := m[1], m[2]
+		src := string(quoted[1 : len(quoted)-1])
+		u, err2 := url.Parse(src)
+		switch {
+		case err2 != nil:
+			err = fmt.Errorf("parsing src %q: %w", src, err2)
+			return attr
+		case u.IsAbs(), len(u.Host) != 0, strings.HasPrefix(u.Path, "/"), len(u.Path) == 0:
+			return attr
+		}
+		u.Path = path.Join(basePath, u.Path)
+		q := quoted[0]
+		return []byte(fmt.Sprintf("%s%c%v%c", name, q, u, q))
+	})
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	buf.Write(rebased)
+	return nil
+}
+
 func (s *Site) addEventResourcesLink(linkHref string, eventBuf *bytes.Buffer) error {
 	// TODO: cache the link template
 	eventLinkPath := path.Join(resources, events, "past-events.html")
@@ -454,3 +1797,242 @@ func (s *Site) addEventResourcesLink(linkHref string, eventBuf *bytes.Buffer) er
 	}
 	return nil
 }
+
+var (
+	audioExts      = []string{".mp3", ".m4a", ".wav"}
+	transcriptExts = []string{".txt", ".vtt"}
+)
+
+// findSibling looks for a file with the same base name as the file, but one of the extensions.
+func (s *Site) findSibling(file string, exts []string) (string, bool) {
+	base := strings.TrimSuffix(file, path.Ext(file))
+	for _, ext := range exts {
+		sibling := base + ext
+		if _, err := fs.Stat(s.fSys, sibling); err == nil {
+			return sibling, true
+		}
+	}
+	return "", false
+}
+
+// addAudioTranscriptPage writes the transcript of the audio file next to the audio resource.
+func (s *Site) addAudioTranscriptPage(audioFile, transcriptFile string, eg *EventGroup) error {
+	text, err := fs.ReadFile(s.fSys, transcriptFile)
+	if err != nil {
+		return fmt.Errorf("reading transcript: %w", err)
+	}
+	audioName := path.Base(audioFile)
+	destDir := path.Join(resources, events, eg.Year)
+	baseName := strings.TrimSuffix(audioName, path.Ext(audioName))
+	data := struct {
+		Audio string
+		Text  string
+	}{
+		Audio: "/" + path.Join(destDir, audioName),
+		Text:  string(text),
+	}
+	pageName := "Transcript of " + baseName
+	destName := path.Join(destDir, audioName+"-transcript.html")
+	if err := s.addPageAs(pageName, "", events, "transcript.html", destName, data); err != nil {
+		return fmt.Errorf("adding transcript page: %w", err)
+	}
+	return nil
+}
+
+func parseEventMeta(data []byte) (*EventMeta, error) {
+	var meta EventMeta
+	m := eventMetaRE.FindSubmatch(data)
+	if m == nil {
+		return &meta, nil
+	}
+	if err := json.Unmarshal(m[1], &meta); err != nil {
+		return nil, fmt.Errorf("parsing meta json: %w", err)
+	}
+	if len(meta.StartDate) != 0 {
+		if _, err := parseEventStartDate(meta.StartDate); err != nil {
+			return nil, err
+		}
+	}
+	return &meta, nil
+}
+
+// parseEventStartDate parses the date or date and time that an event starts.
+func parseEventStartDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("wanted start date in the form of %v or %v, got %q", time.DateOnly, time.RFC3339, s)
+	}
+	return t, nil
+}
+
+type (
+	// eventJSONLD is the schema.org structured data of an event for search engines.
+	eventJSONLD struct {
+		Context     string       `json:"@context"`
+		Type        string       `json:"@type"`
+		Name        string       `json:"name"`
+		StartDate   string       `json:"startDate,omitempty"`
+		Location    *jsonLDThing `json:"location,omitempty"`
+		Performer   *jsonLDThing `json:"performer,omitempty"`
+		Description string       `json:"description,omitempty"`
+		Organizer   *jsonLDThing `json:"organizer,omitempty"`
+	}
+	jsonLDThing struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+	}
+)
+
+// eventJSONLD creates the schema.org Event structured data of the event.
+func (s *Site) eventJSONLD(meta EventMeta) ([]byte, error) {
+	e := eventJSONLD{
+		Context:     "https://schema.org",
+		Type:        "Event",
+		Name:        meta.Name,
+		StartDate:   meta.StartDate,
+		Description: meta.Description,
+	}
+	if len(meta.Location) != 0 {
+		e.Location = &jsonLDThing{Type: "Place", Name: meta.Location}
+	}
+	if len(meta.Speaker) != 0 {
+		e.Performer = &jsonLDThing{Type: "Person", Name: meta.Speaker}
+	}
+	if len(s.Name) != 0 {
+		e.Organizer = &jsonLDThing{Type: "Organization", Name: s.Name}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("creating json-ld: %w", err)
+	}
+	return data, nil
+}
+
+// eventTitle creates a title from the name of an event file such as "009_jane_doe.html".
+func eventTitle(eventHtmlName string) string {
+	name := strings.TrimSuffix(eventHtmlName, path.Ext(eventHtmlName))
+	if _, n, ok := parseEventDatePrefix(name); ok {
+		name = name[n:]
+	}
+	name = strings.TrimLeft(name, "0123456789")
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-'
+	})
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+// eventDate is the date at the start of the name of the event file, such as "2024-01-19_jane_doe.html".
+// The zero time is returned if the date is not known, such as for "009_jane_doe.html".
+func eventDate(eventHtmlName string) time.Time {
+	d, err := parseEventDate(eventHtmlName)
+	if err != nil {
+		return time.Time{}
+	}
+	return d
+}
+
+var eventDateLayouts = []string{"2006-01-02", "2006-01"}
+
+// parseEventDate reads the date at the start of the name of an event file, such as
+// 2024-01-19_jane_doe.html or 2024-01-jane-doe.html.
+func parseEventDate(name string) (time.Time, error) {
+	if d, _, ok := parseEventDatePrefix(name); ok {
+		return d, nil
+	}
+	return time.Time{}, fmt.Errorf("event file name %q does not start with a date such as 2006-01-02_ or 2006-01_", name)
+}
+
+// eventOrder reads the number at the start of the name of an event file, such as 9 for 009_jane_doe.html.
+// The number is the order of the event in its folder, not a date.
+func eventOrder(name string) (int, bool) {
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok || len(prefix) == 0 || strings.Trim(prefix, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(prefix)
+	return n, err == nil
+}
+
+// parseEventDatePrefix reads a date with a year from the start of the name, returning the length of the date.
+func parseEventDatePrefix(name string) (time.Time, int, bool) {
+	for _, layout := range eventDateLayouts {
+		n := len(layout)
+		if len(name) <= n || !strings.ContainsRune("_-.", rune(name[n])) {
+			continue
+		}
+		if d, err := time.Parse(layout, name[:n]); err == nil {
+			return d, n, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// sortEventFiles orders the files of an event folder by their dates, newest first.
+// Files with order numbers instead of dates, such as 009_jane_doe.html, are after the dated files, highest number first.
+// Files with the same date or number are in reverse alphabetical order.
+func (s *Site) sortEventFiles(files []fs.DirEntry) error {
+	dates := make(map[string]time.Time, len(files))
+	orders := make(map[string]int, len(files))
+	var errs []error
+	for _, f := range files {
+		n := f.Name()
+		orders[n] = -1
+		d, err := parseEventDate(n)
+		if err != nil {
+			if order, ok := eventOrder(n); ok {
+				orders[n] = order
+				continue
+			}
+			if !s.LenientDates {
+				errs = append(errs, fmt.Errorf("%w or an order number such as 001_", err))
+				continue
+			}
+			s.logger.Printf("warning: %v or an order number such as 001_", err)
+		}
+		dates[n] = d
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	slices.Reverse(files)
+	slices.SortStableFunc(files, func(a, b fs.DirEntry) int {
+		if c := dates[b.Name()].Compare(dates[a.Name()]); c != 0 {
+			return c
+		}
+		return cmp.Compare(orders[b.Name()], orders[a.Name()])
+	})
+	return nil
+}
+
+// addResourcesTOC writes a json object of the titles of each event resources page by its path.
+func (s *Site) addResourcesTOC(yrs []EventGroup) error {
+	toc := make(map[string]string)
+	for _, yr := range yrs {
+		for _, e := range yr.Entries {
+			if len(e.ResourcesHref) != 0 {
+				toc[e.ResourcesHref] = e.Title
+			}
+		}
+	}
+	data, err := json.Marshal(toc)
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	destDir := path.Join(s.dest, resources)
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	dest := path.Join(destDir, "toc.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing table of contents: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAddImages(t *testing.T) {
+	svg := func(content string, size int) []byte {
+		b := []byte("<svg>" + content + "</svg>")
+		return append(b, bytes.Repeat([]byte(" "), size-len(b))...)
+	}
+	tests := []struct {
+		name   string
+		file   string
+		data   []byte
+		wantOk bool
+	}{
+		{"small webp", "a.webp", bytes.Repeat([]byte("w"), kB50), true},
+		{"oversized webp", "a.webp", bytes.Repeat([]byte("w"), kB50+1), false},
+		{"small gif", "a.gif", bytes.Repeat([]byte("g"), kB50), true},
+		{"oversized gif", "a.gif", bytes.Repeat([]byte("g"), kB50+1), false},
+		{"small svg", "a.svg", svg("<circle/>", kB10), true},
+		{"oversized svg", "a.svg", svg("<circle/>", kB10+1), false},
+		{"svg with script", "a.svg", svg("<script>alert(1)</script>", 100), false},
+		{"svg with uppercase script", "a.svg", svg("<SCRIPT>alert(1)</SCRIPT>", 100), false},
+		{"unexpected extension", "a.bmp", []byte("b"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := fstest.MapFS{
+				"resources/images/" + test.file: &fstest.MapFile{Data: test.data},
+			}
+			s := newTestSite(fSys)
+			err := s.addImages("resources/images", "images", kB50)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				if _, ok := s.files["dest/images/"+test.file]; !ok {
+					t.Errorf("image not written: %v", s.files)
+				}
+			}
+		})
+	}
+}
+
+func TestAddEventFileImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		ext    string
+		size   int
+		wantOk bool
+	}{
+		{"small webp", ".webp", kB50, true},
+		{"oversized webp", ".webp", kB50 + 1, false},
+		{"small gif", ".gif", kB50, true},
+		{"oversized gif", ".gif", kB50 + 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := "resources/events/past/2023"
+			fSys := fstest.MapFS{
+				dir + "/001_jane_doe" + test.ext: &fstest.MapFile{Data: bytes.Repeat([]byte("w"), test.size)},
+			}
+			entries, err := fs.ReadDir(fSys, dir)
+			if err != nil {
+				t.Fatalf("reading fixture directory: %v", err)
+			}
+			s := newTestSite(fSys)
+			eg := &EventGroup{Year: "2023"}
+			err = s.addEventFile(eg, dir, eg.Year, entries[0])
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				if _, ok := s.files["dest/images/events/2023/001_jane_doe"+test.ext]; !ok {
+					t.Errorf("image not written: %v", s.files)
+				}
+				if want, got := []string{"/images/events/2023/001_jane_doe" + test.ext}, eg.Images; len(got) != 1 || want[0] != got[0] {
+					t.Errorf("wanted event images %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAddEventFileDocuments(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{"bird-walk-map.pdf", `<a href="/resources/events/2023/bird-walk-map.pdf">bird walk map</a>` + "\n"},
+		{"reading-list.docx", `<a href="/resources/events/2023/reading-list.docx">reading list</a>` + "\n"},
+		{"budget.xlsx", `<a href="/resources/events/2023/budget.xlsx">budget</a>` + "\n"},
+	}
+	for _, test := range tests {
+		for _, oneResource := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%v one resource=%v", test.fileName, oneResource), func(t *testing.T) {
+				dir := "resources/events/past/2023"
+				fSys := fstest.MapFS{
+					dir + "/" + test.fileName: &fstest.MapFile{Data: []byte("document")},
+				}
+				entries, err := fs.ReadDir(fSys, dir)
+				if err != nil {
+					t.Fatalf("reading fixture directory: %v", err)
+				}
+				s := newTestSite(fSys)
+				s.OneResource = oneResource
+				eg := &EventGroup{Year: "2023"}
+				if err := s.addEventFile(eg, dir, eg.Year, entries[0]); err != nil {
+					t.Fatalf("unwanted error: %v", err)
+				}
+				if _, ok := s.files["dest/resources/events/2023/"+test.fileName]; !ok {
+					t.Errorf("resource not written: %v", s.files)
+				}
+				got := eg.Documents.String()
+				if oneResource {
+					got = eg.Resources.String()
+				}
+				if test.want != got {
+					t.Errorf("not equal: \n wanted: %q \n got:    %q", test.want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestPastEventsDocumentLinks(t *testing.T) {
+	fSys := testEventsFS()
+	for _, name := range []string{"past-events.html", "videos-and-resources.html"} {
+		data, err := fs.ReadFile(_siteFS, "resources/events/"+name)
+		if err != nil {
+			t.Fatalf("reading template: %v", err)
+		}
+		fSys["resources/events/"+name] = &fstest.MapFile{Data: data}
+	}
+	fSys["resources/events/past/2023/001_jane_doe.html"] = testEvent("birds", "")
+	fSys["resources/events/past/2023/001_bird-walk-map.pdf"] = &fstest.MapFile{Data: []byte("document")}
+	want := `<a href="/resources/events/2023/001_bird-walk-map.pdf">bird walk map</a>`
+	tests := []struct {
+		name        string
+		oneResource bool
+		file        string
+	}{
+		{"past events", false, "dest/past-events.html"},
+		{"one resource", true, "dest/videos-and-resources.html"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(fSys)
+			s.OneResource = test.oneResource
+			if err := s.addPastEvents(); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if got := string(s.files[test.file]); !strings.Contains(got, want) {
+				t.Errorf("wanted %v to contain %q, got:\n%s", test.file, want, got)
+			}
+		})
+	}
+}
+
+func TestMimeTypeFor(t *testing.T) {
+	tests := []struct {
+		ext  string
+		want string
+	}{
+		{".jpg", "image/jpeg"},
+		{".PDF", "application/pdf"},
+		{".docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{".mp3", "audio/mpeg"},
+		{".exe", "application/octet-stream"},
+		{"", "application/octet-stream"},
+	}
+	for _, test := range tests {
+		t.Run(test.ext, func(t *testing.T) {
+			if got := mimeTypeFor(test.ext); test.want != got {
+				t.Errorf("not equal: wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,1859 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type testSite struct {
+	Site
+	files   map[string][]byte
+	filesMu sync.Mutex
+	logs    bytes.Buffer
+}
+
+func newTestSite(fSys fstest.MapFS) *testSite {
+	ts := &testSite{
+		files: make(map[string][]byte),
+	}
+	ts.Site = Site{
+		fSys:        fSys,
+		dest:        "dest",
+		Name:        "TestSite",
+		Description: "Test Description",
+		BasePath:    "/",
+		removeAll: func(path string) error {
+			for k := range ts.files {
+				if strings.HasPrefix(k, path) {
+					delete(ts.files, k)
+				}
+			}
+			return nil
+		},
+		mkdirAll: func(path string) error { return nil },
+		writeFile: func(name string, data []byte) error {
+			ts.filesMu.Lock()
+			defer ts.filesMu.Unlock()
+			ts.files[name] = data
+			return nil
+		},
+		isNotExist:       os.IsNotExist,
+		mu:               new(sync.Mutex),
+		baseTemplateOnce: new(sync.Once),
+		now: func() time.Time {
+			return time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+		},
+	}
+	ts.logger = log.New(&ts.logs, "", 0)
+	return ts
+}
+
+func testMainFS() fstest.MapFS {
+	return fstest.MapFS{
+		"resources/main.html": &fstest.MapFile{Data: []byte(`<title>{{.Page.Name}}</title>{{template "nav.html" .}}<main>{{template "content" .Page.Data}}</main>`)},
+		"resources/index.css": &fstest.MapFile{Data: []byte(`body {}`)},
+		"resources/nav.html":  &fstest.MapFile{Data: []byte(`<nav></nav>`)},
+		"resources/nav.css":   &fstest.MapFile{Data: []byte(`nav {}`)},
+		"resources/home.html": &fstest.MapFile{Data: []byte(`{{define "content"}}home{{end}}`)},
+	}
+}
+
+func testEventsFS() fstest.MapFS {
+	fSys := testMainFS()
+	fSys["resources/events/future-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Events.String}}{{end}}`)}
+	fSys["resources/events/past-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{range .Years}}{{.Year}}:{{.Events.String}}{{end}}{{end}}{{define "event-resource-link"}}<a href="{{.}}">Video/Resources</a>{{end}}`)}
+	fSys["resources/events/videos-and-resources.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{range .}}{{.Year}}:{{.Resources.String}}{{end}}{{end}}`)}
+	return fSys
+}
+
+func testEvent(event, resources string) *fstest.MapFile {
+	data := `{{define "event"}}` + event + `{{end}}{{define "resources"}}` + resources + `{{end}}`
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestTrackPageName(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		wantOk   bool
+		wantLogs bool
+	}{
+		{"strict", true, false, false},
+		{"lenient", false, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.StrictPageNames = test.strict
+			if err := s.trackPageName("Home", "a.html"); err != nil {
+				t.Fatalf("unwanted error tracking first page name: %v", err)
+			}
+			err := s.trackPageName("Home", "b.html")
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			}
+			gotLogs := s.logs.String()
+			if want, got := test.wantLogs, strings.Contains(gotLogs, "a.html"); want != got {
+				t.Errorf("wanted warning logged: %v, got logs: %q", want, gotLogs)
+			}
+		})
+	}
+}
+
+func TestAddPageDuplicateName(t *testing.T) {
+	s := newTestSite(testMainFS())
+	s.StrictPageNames = true
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("unwanted error adding page: %v", err)
+	}
+	if _, ok := s.files["dest/home.html"]; !ok {
+		t.Errorf("home page not written: %v", s.files)
+	}
+	if err := s.addPage("Home Page", "", "home.html", nil); err == nil {
+		t.Errorf("wanted error adding page with duplicate name")
+	}
+}
+
+func TestAddAudioTranscriptPage(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/events/transcript.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Audio}}|{{html .Text}}{{end}}`)}
+	fSys["resources/events/past/2023/001_talk.mp3"] = &fstest.MapFile{Data: []byte("audio")}
+	fSys["resources/events/past/2023/001_talk.vtt"] = &fstest.MapFile{Data: []byte("hello & goodbye")}
+	fSys["resources/events/past/2023/002_lonely.txt"] = &fstest.MapFile{Data: []byte("no audio")}
+	s := newTestSite(fSys)
+	dir := "resources/events/past/2023"
+	entries, err := fs.ReadDir(fSys, dir)
+	if err != nil {
+		t.Fatalf("reading fixture directory: %v", err)
+	}
+	eg := &EventGroup{Year: "2023"}
+	for _, de := range entries {
+		if err := s.addEventFile(eg, dir, eg.Year, de); err != nil {
+			t.Fatalf("unwanted error adding %v: %v", de.Name(), err)
+		}
+	}
+	got, ok := s.files["dest/resources/events/2023/001_talk.mp3-transcript.html"]
+	if !ok {
+		t.Fatalf("transcript page not written: %v", s.files)
+	}
+	if want := "/resources/events/2023/001_talk.mp3|hello &amp; goodbye"; !strings.Contains(string(got), want) {
+		t.Errorf("wanted transcript page to contain %q, got %q", want, got)
+	}
+	if _, ok := s.files["dest/resources/events/2023/001_talk.mp3"]; !ok {
+		t.Errorf("audio file not written")
+	}
+	if want, got := "002_lonely.txt", s.logs.String(); !strings.Contains(got, want) {
+		t.Errorf("wanted warning about %q, got %q", want, got)
+	}
+}
+
+func TestCompressPDFs(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		data       string
+		compressed string
+		maxSize    int
+		wantCalls  int
+		wantOk     bool
+		want       string
+	}{
+		{"compressed", "a.pdf", "1234567890", "12345", 5, 1, true, "12345"},
+		{"larger compressed", "b.pdf", "12345", "1234567890", 5, 1, true, "12345"},
+		{"too large", "c.pdf", "1234567890", "123456", 5, 1, false, ""},
+		{"not pdf", "d.docx", "12345", "1", 5, 0, true, "12345"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := fstest.MapFS{
+				"src/" + test.file: &fstest.MapFile{Data: []byte(test.data)},
+			}
+			s := newTestSite(fSys)
+			s.CompressPDFs = true
+			calls := 0
+			s.pdfCompressor = func(data []byte) ([]byte, error) {
+				calls++
+				return []byte(test.compressed), nil
+			}
+			entries, err := fs.ReadDir(fSys, "src")
+			if err != nil {
+				t.Fatalf("reading fixture directory: %v", err)
+			}
+			p, err := s.addBinaryFile(entries[0], "src", "out", test.maxSize)
+			switch {
+			case test.wantCalls != calls:
+				t.Errorf("wanted compressor to be called %v times, got %v", test.wantCalls, calls)
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				if want, got := "/out/"+test.file, p; want != got {
+					t.Errorf("returned path not equal: wanted %q, got %q", want, got)
+				}
+				if want, got := test.want, string(s.files[s.dest+p]); want != got {
+					t.Errorf("written file not equal: wanted %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckNonEmptyEventDirs(t *testing.T) {
+	fSys := fstest.MapFS{
+		"resources/events/future/001_a.html":    &fstest.MapFile{},
+		"resources/events/past/2022/001_b.jpg":  &fstest.MapFile{},
+		"resources/events/past/2023/001_c.html": &fstest.MapFile{},
+	}
+	s := newTestSite(fSys)
+	err := s.checkNonEmptyEventDirs()
+	if err == nil {
+		t.Fatalf("wanted error for empty directory")
+	}
+	msg := err.Error()
+	if want := "resources/events/past/2022"; !strings.Contains(msg, want) {
+		t.Errorf("wanted error to contain %q, got %q", want, msg)
+	}
+	for _, notWant := range []string{"future", "2023"} {
+		if strings.Contains(msg, notWant) {
+			t.Errorf("did not want error to contain %q, got %q", notWant, msg)
+		}
+	}
+}
+
+func TestSocialShareURL(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     string
+	}{
+		{"twitter", "https://twitter.com/intent/tweet?text=Talk+%26+Tea&url=https%3A%2F%2Fexample.com%2Fa.html"},
+		{"facebook", "https://www.facebook.com/sharer/sharer.php?u=https%3A%2F%2Fexample.com%2Fa.html"},
+		{"linkedin", "https://www.linkedin.com/sharing/share-offsite/?url=https%3A%2F%2Fexample.com%2Fa.html"},
+		{"myspace", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.platform, func(t *testing.T) {
+			s := newTestSite(nil)
+			got := s.socialShareURL(test.platform, "https://example.com/a.html", "Talk & Tea")
+			if test.want != got {
+				t.Errorf("not equal: \n wanted: %q \n got:    %q", test.want, got)
+			}
+		})
+	}
+	t.Run("template", func(t *testing.T) {
+		s := newTestSite(nil)
+		tmpl := s.newTemplate("")
+		if _, err := tmpl.Parse(`{{shareURL "facebook" "x" "y"}}`); err != nil {
+			t.Fatalf("parsing template: %v", err)
+		}
+		var sb strings.Builder
+		if err := s.executeTemplate(&sb, tmpl, nil); err != nil {
+			t.Fatalf("executing template: %v", err)
+		}
+		if want, got := "https://www.facebook.com/sharer/sharer.php?u=x", sb.String(); want != got {
+			t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+		}
+	})
+}
+
+func TestFormatDate(t *testing.T) {
+	d := time.Date(2023, time.August, 18, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{"January 2, 2006", "August 18, 2023"},
+		{"2006-01-02", "2023-08-18"},
+		{"Mon 3:04 PM", "Fri 7:00 PM"},
+	}
+	for _, test := range tests {
+		if got := formatDate(d, test.layout); test.want != got {
+			t.Errorf("formatting with %q: not equal: wanted %q, got %q", test.layout, test.want, got)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxBytes int
+		want     string
+	}{
+		{"short", "abc", 5, "abc"},
+		{"exact", "abcde", 5, "abcde"},
+		{"long", "abcdef", 5, "abcde"},
+		{"zero", "abc", 0, ""},
+		{"negative", "abc", -1, ""},
+		{"multi-byte boundary", "café!", 4, "caf"},
+		{"multi-byte whole", "café!", 5, "café"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := truncateText(test.s, test.maxBytes); test.want != got {
+				t.Errorf("not equal: wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"https://example.com/a?b=c", "https://example.com/a?b=c"},
+		{"http://example.com", "http://example.com"},
+		{"/events/2023.html", "/events/2023.html"},
+		{"mailto:info@example.com", "mailto:info@example.com"},
+		{"javascript:alert(1)", ""},
+		{" JavaScript:alert(1)", ""},
+		{"data:text/html,<b>hi</b>", ""},
+		{"http://[::1", ""},
+	}
+	for _, test := range tests {
+		if got := safeURL(test.s); test.want != got {
+			t.Errorf("making %q safe: not equal: wanted %q, got %q", test.s, test.want, got)
+		}
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	s := newTestSite(nil)
+	tmpl := s.newTemplate("")
+	src := `{{formatDate . "Jan 2"}}|{{truncateText "abcdef" 3}}|{{safeURL "javascript:x"}}{{safeURL "/a.html"}}`
+	if _, err := tmpl.Parse(src); err != nil {
+		t.Fatalf("parsing template: %v", err)
+	}
+	var sb strings.Builder
+	d := time.Date(2023, time.August, 18, 0, 0, 0, 0, time.UTC)
+	if err := s.executeTemplate(&sb, tmpl, d); err != nil {
+		t.Fatalf("executing template: %v", err)
+	}
+	if want, got := "Aug 18|abc|/a.html", sb.String(); want != got {
+		t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestMaxFutureEvents(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/events/future-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Events.String}}{{end}}`)}
+	fSys["resources/events/future/001_a.html"] = &fstest.MapFile{Data: []byte(`{{define "event"}}[event a]{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/future/002_b.html"] = &fstest.MapFile{Data: []byte(`{{define "event"}}[event b]{{end}}{{define "resources"}}{{end}}`)}
+	s := newTestSite(fSys)
+	s.MaxFutureEvents = 1
+	if err := s.addFutureEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/future-events.html"])
+	if want := "[event b]"; !strings.Contains(got, want) {
+		t.Errorf("wanted page to contain most recent event %q, got %q", want, got)
+	}
+	if notWant := "[event a]"; strings.Contains(got, notWant) {
+		t.Errorf("did not want page to contain %q, got %q", notWant, got)
+	}
+	if want, got := "only showing 1 of 2", s.logs.String(); !strings.Contains(got, want) {
+		t.Errorf("wanted warning %q, got %q", want, got)
+	}
+}
+
+func TestMaxFutureEventsByDate(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/events/future-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Events.String}}{{end}}`)}
+	fSys["resources/events/future/001_a.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"startDate": "2023-10-20"} */}}{{define "event"}}[event a]{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/future/002_b.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"startDate": "2023-09-15"} */}}{{define "event"}}[event b]{{end}}{{define "resources"}}{{end}}`)}
+	fSys["resources/events/future/003_c.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"startDate": "2023-08-18"} */}}{{define "event"}}[event c]{{end}}{{define "resources"}}{{end}}`)}
+	s := newTestSite(fSys)
+	s.MaxFutureEvents = 2
+	if err := s.addFutureEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/future-events.html"])
+	if want := "[event a][event b]"; !strings.Contains(got, want) {
+		t.Errorf("wanted page to contain the most recent events by date, %q, got %q", want, got)
+	}
+	if notWant := "[event c]"; strings.Contains(got, notWant) {
+		t.Errorf("did not want page to contain %q, got %q", notWant, got)
+	}
+	if want, got := 2, len(s.futureEvents.Entries); want != got {
+		t.Errorf("wanted %v future event entries, got %v", want, got)
+	}
+}
+
+func TestMaxFilenameLen(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		wantOk bool
+	}{
+		{"at limit", "123456.txt", true},
+		{"too long", "1234567.txt", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := fstest.MapFS{
+				"resources/" + test.file: &fstest.MapFile{Data: []byte("data")},
+			}
+			s := newTestSite(fSys)
+			s.MaxFilenameLen = 10
+			err := s.addStatic("", "", test.file)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"009_david_fenner.html", "David Fenner"},
+		{"001_paul-bannick.html", "Paul Bannick"},
+		{"westsound_wildlife.html", "Westsound Wildlife"},
+	}
+	for _, test := range tests {
+		if want, got := test.want, eventTitle(test.name); want != got {
+			t.Errorf("title of %q: wanted %q, got %q", test.name, want, got)
+		}
+	}
+}
+
+func TestAddResourcesTOC(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2023/001_jane_doe.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"name": "Jane Doe: Birds"} */}}` +
+		`{{define "event"}}jane{{end}}{{define "resources"}}video{{end}}`)}
+	fSys["resources/events/past/2023/002_john_smith.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"name": "John Smith: Energy"} */}}` +
+		`{{define "event"}}john{{end}}{{define "resources"}}slides{{end}}`)}
+	fSys["resources/events/past/2023/003_no_resources.html"] = testEvent("none", "")
+	s := newTestSite(fSys)
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/resources/toc.json"])
+	want := `{"resources/events/2023/001_jane_doe.html":"Jane Doe: Birds","resources/events/2023/002_john_smith.html":"John Smith: Energy"}`
+	if want != got {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestNavAriaLabel(t *testing.T) {
+	fSys := testMainFS()
+	nav, err := fs.ReadFile(_siteFS, "resources/nav.html")
+	if err != nil {
+		t.Fatalf("reading nav template: %v", err)
+	}
+	fSys["resources/nav.html"] = &fstest.MapFile{Data: nav}
+	s := newTestSite(fSys)
+	s.NavAriaLabel = "TestSite navigation"
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("unwanted error adding page: %v", err)
+	}
+	got := string(s.files["dest/home.html"])
+	if want := `<nav aria-label="TestSite navigation">`; !strings.Contains(got, want) {
+		t.Errorf("wanted page to contain %q, got %q", want, got)
+	}
+}
+
+func TestAddCSSBundle(t *testing.T) {
+	fSys := fstest.MapFS{
+		"resources/css/b.css": &fstest.MapFile{Data: []byte("b {}")},
+		"resources/css/a.css": &fstest.MapFile{Data: []byte("a {}")},
+	}
+	s := newTestSite(fSys)
+	if err := s.addCSSBundle(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := "/* a.css */\na {}\n/* b.css */\nb {}\n"
+	if got := string(s.files["dest/bundle.css"]); want != got {
+		t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestEventDate(t *testing.T) {
+	tests := []struct {
+		name string
+		want time.Time
+	}{
+		{"2023-09-15_david_fenner.html", time.Date(2023, 9, 15, 0, 0, 0, 0, time.UTC)},
+		{"001_x.html", time.Time{}}, // an order number, not January
+		{"009_drea_bowen.html", time.Time{}},
+		{"013_david_fenner.html", time.Time{}},
+		{"david_fenner.html", time.Time{}},
+	}
+	for _, test := range tests {
+		if want, got := test.want, eventDate(test.name); !want.Equal(got) {
+			t.Errorf("date of %v: wanted %v, got %v", test.name, want, got)
+		}
+	}
+}
+
+func TestEventOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		wantOk bool
+		want   int
+	}{
+		{"001_x.html", true, 1},
+		{"013_david_fenner.html", true, 13},
+		{"2024-01_jane_doe.html", false, 0},
+		{"_jane_doe.html", false, 0},
+		{"jane_doe.html", false, 0},
+	}
+	for _, test := range tests {
+		got, ok := eventOrder(test.name)
+		if test.wantOk != ok || test.want != got {
+			t.Errorf("order of %v: wanted %v (%v), got %v (%v)", test.name, test.want, test.wantOk, got, ok)
+		}
+	}
+}
+
+func TestMicroformats(t *testing.T) {
+	fSys := testEventsFS()
+	pastEvents, err := fs.ReadFile(_siteFS, "resources/events/past-events.html")
+	if err != nil {
+		t.Fatalf("reading past events template: %v", err)
+	}
+	fSys["resources/events/past-events.html"] = &fstest.MapFile{Data: pastEvents}
+	fSys["resources/events/past/2023/2023-04-01_jane_doe.html"] = testEvent("<p>birds</p>", "video")
+	tests := []struct {
+		name         string
+		microformats bool
+		wantParts    []string
+	}{
+		{"disabled", false, []string{`<p>birds</p>`}},
+		{"enabled", true, []string{
+			`class="past events h-feed"`,
+			`class="h-entry"`,
+			`<data class="p-name" value="Jane Doe"></data>`,
+			`<time class="dt-published" datetime="2023-04-01"></time>`,
+			`<div class="e-content"><p>birds</p></div>`,
+			`href="resources/events/2023/2023-04-01_jane_doe.html"`,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(fSys)
+			s.Microformats = test.microformats
+			if err := s.addPastEvents(); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			got := string(s.files["dest/past-events.html"])
+			for _, want := range test.wantParts {
+				if !strings.Contains(got, want) {
+					t.Errorf("wanted page to contain %q, got:\n%v", want, got)
+				}
+			}
+			if want, got := test.microformats, strings.Contains(got, "h-entry"); want != got {
+				t.Errorf("wanted h-entry on page: %v, got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestDeprecatedTemplateBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  string
+		wantOk bool
+	}{
+		{"current", `{{define "event"}}a{{end}}{{define "resources"}}b{{end}}`, true},
+		{"deprecated", `{{define "event"}}a{{end}}{{define "resources"}}b{{end}}{{define "event-video"}}c{{end}}`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := testEventsFS()
+			fSys["resources/events/past/2023/001_a.html"] = &fstest.MapFile{Data: []byte(test.event)}
+			s := newTestSite(fSys)
+			s.DeprecatedTemplateBlocks = []string{"event-video"}
+			err := s.addPastEvents()
+			switch {
+			case !test.wantOk:
+				if err == nil || !strings.Contains(err.Error(), "001_a.html defines deprecated templates: event-video") {
+					t.Errorf("wanted error about deprecated template, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRebaseImagePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"parent", `<img src="../images/photo.jpg">`, `<img src="/images/photo.jpg">`},
+		{"relative", `<img alt="x" src='images/a b.jpg'>`, `<img alt="x" src='/images/a%20b.jpg'>`},
+		{"absolute", `<img src="/images/photo.jpg">`, `<img src="/images/photo.jpg">`},
+		{"external", `<iframe src="https://www.youtube.com/embed/x"></iframe>`, `<iframe src="https://www.youtube.com/embed/x"></iframe>`},
+		{"data", `<img src="data:image/png;base64,AAAA">`, `<img src="data:image/png;base64,AAAA">`},
+		{"not attribute", `<p>datasrc="a.jpg"</p>`, `<p>datasrc="a.jpg"</p>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			buf := bytes.NewBufferString(test.html)
+			if err := s.rebaseImagePaths(buf, "/"); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if want, got := test.want, buf.String(); want != got {
+				t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+			}
+		})
+	}
+}
+
+func TestAuditResourceLinks(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2023/001_a.html"] = testEvent("a", `<a href="/resources/events/2023/001_a.pdf">slides</a>`)
+	fSys["resources/events/past/2023/001_a.pdf"] = &fstest.MapFile{Data: []byte("pdf")}
+	fSys["resources/events/past/2023/002_b.html"] = testEvent("b", `<a href="/resources/events/2023/002_missing.pdf">slides</a>`)
+	s := newTestSite(fSys)
+	eg := &EventGroup{Year: "2023"}
+	dir := "resources/events/past/2023"
+	for _, name := range []string{"001_a.html", "002_b.html"} {
+		if err := s.addEvent(eg, dir, name, eg.Year); err != nil {
+			t.Fatalf("adding event %v: %v", name, err)
+		}
+	}
+	errs := s.auditResourceLinks(eg)
+	if want, got := 1, len(errs); want != got {
+		t.Fatalf("wanted %v errors, got %v: %v", want, got, errs)
+	}
+	if want, got := "002_missing.pdf", errs[0].Error(); !strings.Contains(got, want) {
+		t.Errorf("wanted error to contain %q, got %q", want, got)
+	}
+}
+
+func TestAddTranslatedPage(t *testing.T) {
+	fSys := testMainFS()
+	mainHTML, err := fs.ReadFile(_siteFS, "resources/main.html")
+	if err != nil {
+		t.Fatalf("reading main template: %v", err)
+	}
+	fSys["resources/main.html"] = &fstest.MapFile{Data: mainHTML}
+	fSys["resources/home.es.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}hola{{end}}`)}
+	s := newTestSite(fSys)
+	s.Languages = []string{"es", "fr"}
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("adding page: %v", err)
+	}
+	for _, lang := range s.Languages {
+		if err := s.addTranslatedPage("Home Page", "", lang, "", "home.html", nil); err != nil {
+			t.Fatalf("adding %v translation: %v", lang, err)
+		}
+	}
+	got, ok := s.files["dest/es/home.html"]
+	if !ok {
+		t.Fatalf("spanish page not written: %v", s.files)
+	}
+	if _, ok := s.files["dest/fr/home.html"]; ok {
+		t.Errorf("french page written without translation")
+	}
+	wantLinks := []string{
+		`<link rel="alternate" hreflang="x-default" href="/home.html">`,
+		`<link rel="alternate" hreflang="es" href="/es/home.html">`,
+	}
+	pages := map[string]string{
+		"home.html":    string(s.files["dest/home.html"]),
+		"es/home.html": string(got),
+	}
+	for name, page := range pages {
+		for _, want := range wantLinks {
+			if !strings.Contains(page, want) {
+				t.Errorf("wanted %v to contain %q, got:\n%v", name, want, page)
+			}
+		}
+	}
+	if want := `<html lang="es">`; !strings.Contains(string(got), want) {
+		t.Errorf("wanted spanish page to contain %q", want)
+	}
+	if want := "hola"; !strings.Contains(string(got), want) {
+		t.Errorf("wanted spanish page to contain %q", want)
+	}
+}
+
+func TestAddInitJS(t *testing.T) {
+	for _, enableJS := range []bool{true, false} {
+		s := newTestSite(nil)
+		s.fSys = _siteFS
+		s.MaxResourceSize = mB10
+		s.EnableJS = enableJS
+		if err := s.addMain(); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		_, ok := s.files["dest/init.js"]
+		if want, got := enableJS, ok; want != got {
+			t.Errorf("wanted init.js written to be %v when js is enabled is %v", want, enableJS)
+		}
+		wantScript := `<script src="/init.js" defer></script>`
+		if want, got := enableJS, strings.Contains(string(s.files["dest/home.html"]), wantScript); want != got {
+			t.Errorf("wanted home page to have init script to be %v when js is enabled is %v", want, enableJS)
+		}
+	}
+}
+
+func TestAddGalleryPage(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/gallery.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{range .}}<img src="{{.Src}}">{{end}}{{end}}`)}
+	for _, year := range []string{"2022", "2023"} {
+		for _, name := range []string{"001_jane_doe", "002_john_smith"} {
+			dir := "resources/events/past/" + year + "/"
+			fSys[dir+name+".html"] = testEvent(name, "")
+			fSys[dir+name+".jpg"] = &fstest.MapFile{Data: []byte("jpg")}
+		}
+	}
+	s := newTestSite(fSys)
+	s.GenerateGallery = true
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	i := slices.IndexFunc(s.pages, func(p Page) bool {
+		return p.Path == "/gallery.html"
+	})
+	if i < 0 {
+		t.Fatalf("gallery page not added: %v", s.pages)
+	}
+	want := []GalleryImage{
+		{"/images/events/2023/002_john_smith.jpg", "photo of John Smith", "2023"},
+		{"/images/events/2023/001_jane_doe.jpg", "photo of Jane Doe", "2023"},
+		{"/images/events/2022/002_john_smith.jpg", "photo of John Smith", "2022"},
+		{"/images/events/2022/001_jane_doe.jpg", "photo of Jane Doe", "2022"},
+	}
+	if got := s.pages[i].Data; !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	if want, got := 4, strings.Count(string(s.files["dest/gallery.html"]), "<img"); want != got {
+		t.Errorf("wanted %v images on gallery page, got %v", want, got)
+	}
+}
+
+func TestAuditEventDateConflicts(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2023/2023-04-21_bird_walk.html"] = testEvent("walk", "")
+	fSys["resources/events/past/2023/2023-04-21_jane_doe.html"] = testEvent("birds", "")
+	fSys["resources/events/past/2023/2023-05-19_john_smith.html"] = testEvent("energy", "")
+	fSys["resources/events/past/2023/006_ann_lee.html"] = testEvent("trees", "") // order numbers are not dates
+	fSys["resources/events/past/2023/007_ann_lee.html"] = testEvent("trees", "")
+	s := newTestSite(fSys)
+	s.Verbose = true
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := "warning: events on the same date: 2023-04-21: 2023/2023-04-21_jane_doe.html, 2023/2023-04-21_bird_walk.html\n"
+	if got := s.logs.String(); want != got {
+		t.Errorf("logs not equal:\nwanted: %q\ngot:    %q", want, got)
+	}
+}
+
+func TestAddEventEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantOk    bool
+		wantEvent string
+	}{
+		{"utf-8", `{{define "event"}}café{{end}}{{define "resources"}}{{end}}`, true, "café"},
+		{"bom", "\xEF\xBB\xBF" + `{{/* meta: {"name": "Café"} */}}{{define "event"}}café{{end}}{{define "resources"}}{{end}}`, true, "café"},
+		{"latin-1", "{{define \"event\"}}caf\xE9{{end}}{{define \"resources\"}}{{end}}", false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := testEventsFS()
+			fSys["resources/events/past/2023/001_cafe.html"] = &fstest.MapFile{Data: []byte(test.data)}
+			s := newTestSite(fSys)
+			eg := &EventGroup{Year: "2023"}
+			err := s.addEvent(eg, "resources/events/past/2023", "001_cafe.html", eg.Year)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.wantEvent != eg.Events.String():
+				t.Errorf("wanted event %q, got %q", test.wantEvent, eg.Events.String())
+			}
+		})
+	}
+}
+
+func TestStripBOM(t *testing.T) {
+	s := newTestSite(nil)
+	if want, got := "abc", string(s.stripBOM([]byte("\xEF\xBB\xBFabc"))); want != got {
+		t.Errorf("wanted %q, got %q", want, got)
+	}
+	if want, got := "abc", string(s.stripBOM([]byte("abc"))); want != got {
+		t.Errorf("wanted %q, got %q", want, got)
+	}
+}
+
+func TestAddAppShell(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/main.html"] = &fstest.MapFile{Data: []byte(`<header>{{.Site.Name}}</header>{{template "nav.html" .}}<main><h3>{{.Page.Name}}</h3>{{template "content" .Page.Data}}</main><footer></footer>`)}
+	fSys["resources/app-shell.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{end}}`)}
+	fSys["resources/events/future/001_jane_doe.html"] = testEvent("EVENT_CONTENT", "")
+	s := newTestSite(fSys)
+	if err := s.addFutureEvents(); err != nil {
+		t.Fatalf("adding future events: %v", err)
+	}
+	if err := s.addAppShell(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := `<header>TestSite</header><nav></nav><main id="app"></main><footer></footer>`
+	got := string(s.files["dest/app-shell.html"])
+	if want != got {
+		t.Errorf("not equal:\nwanted: %q\ngot:    %q", want, got)
+	}
+	if strings.Contains(got, "EVENT_CONTENT") {
+		t.Errorf("did not want event content in app shell")
+	}
+}
+
+func TestFingerprintAssets(t *testing.T) {
+	img := []byte("png")
+	sum := sha256.Sum256(img)
+	hashed := "/images/a." + hex.EncodeToString(sum[:4]) + ".png"
+	tests := []struct {
+		name              string
+		fingerprintAssets bool
+		wantImage         string
+	}{
+		{"fingerprinted", true, hashed},
+		{"plain", false, "/images/a.png"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := testMainFS()
+			fSys["resources/main.html"] = &fstest.MapFile{Data: []byte(`<link href="{{.Asset "/images/a.png"}}">{{template "content" .Page.Data}}`)}
+			fSys["resources/home.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}<img src="/images/a.png"><img src="/images/a.png.txt">{{end}}`)}
+			fSys["resources/images/a.png"] = &fstest.MapFile{Data: img}
+			s := newTestSite(fSys)
+			s.FingerprintAssets = test.fingerprintAssets
+			if err := s.addImages("resources/images", "images", kB50); err != nil {
+				t.Fatalf("adding images: %v", err)
+			}
+			if err := s.addPage("Home", "", "home.html", nil); err != nil {
+				t.Fatalf("adding page: %v", err)
+			}
+			if _, ok := s.files["dest"+test.wantImage]; !ok {
+				t.Errorf("wanted image written to %v: %v", test.wantImage, s.files)
+			}
+			want := `<link href="` + test.wantImage + `"><img src="` + test.wantImage + `"><img src="/images/a.png.txt">`
+			if got := string(s.files["dest/home.html"]); want != got {
+				t.Errorf("pages not equal: \n wanted: %q \n got:    %q", want, got)
+			}
+		})
+	}
+}
+
+func TestAddPastEventsPages(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past-events.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{range .Years}}{{.Year}},{{end}}|{{.PageNumber}}/{{.TotalPages}}|{{.PrevPath}}|{{.NextPath}}{{end}}`)}
+	for _, year := range []string{"2019", "2020", "2021", "2022", "2023"} {
+		fSys["resources/events/past/"+year+"/001_a.html"] = testEvent("[a]", "")
+	}
+	s := newTestSite(fSys)
+	s.PastEventsPageSize = 2
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := map[string]string{
+		"dest/past-events.html":   "<main>2023,2022,|1/3||/past-events-2.html</main>",
+		"dest/past-events-2.html": "<main>2021,2020,|2/3|/past-events.html|/past-events-3.html</main>",
+		"dest/past-events-3.html": "<main>2019,|3/3|/past-events-2.html|</main>",
+	}
+	for name, wantContent := range want {
+		got, ok := s.files[name]
+		if !ok {
+			t.Errorf("wanted %v to be written", name)
+			continue
+		}
+		if !strings.Contains(string(got), wantContent) {
+			t.Errorf("%v: wanted to contain %q, got %q", name, wantContent, got)
+		}
+	}
+	if _, ok := s.files["dest/past-events-4.html"]; ok {
+		t.Errorf("did not want fourth page")
+	}
+	if want, got := "/past-events-3.html#year-2019", s.pastEventsYearPath("2019"); want != got {
+		t.Errorf("year paths not equal: wanted %q, got %q", want, got)
+	}
+	t.Run("unlimited", func(t *testing.T) {
+		s := newTestSite(fSys)
+		if err := s.addPastEvents(); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		want := "<main>2023,2022,2021,2020,2019,|1/1||</main>"
+		if got := string(s.files["dest/past-events.html"]); !strings.Contains(got, want) {
+			t.Errorf("wanted to contain %q, got %q", want, got)
+		}
+		if _, ok := s.files["dest/past-events-2.html"]; ok {
+			t.Errorf("did not want second page")
+		}
+	})
+}
+
+func TestAddPastEventsConcurrently(t *testing.T) {
+	fSys := testEventsFS()
+	var want strings.Builder
+	for y := 2030; y >= 2000; y-- {
+		year := strconv.Itoa(y)
+		fSys["resources/events/past/"+year+"/001_a.html"] = testEvent("["+year+" a]", "[resources "+year+"]")
+		fSys["resources/events/past/"+year+"/002_b.html"] = testEvent("["+year+" b]", "")
+		fmt.Fprintf(&want, "%v:[%v b][%v a]", year, year, year)
+	}
+	for _, concurrency := range []int{0, 1, 4, 64} {
+		t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+			s := newTestSite(fSys)
+			s.Concurrency = concurrency
+			if err := s.addPastEvents(); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			got := string(s.files["dest/past-events.html"])
+			got = eventResourceLinkRE.ReplaceAllString(got, "")
+			if !strings.Contains(got, want.String()) {
+				t.Errorf("wanted years in descending order: \n wanted: %q \n got:    %q", want.String(), got)
+			}
+			if want, got := 31, len(s.pastEvents); want != got {
+				t.Errorf("wanted %v past event years, got %v", want, got)
+			}
+		})
+	}
+	t.Run("error", func(t *testing.T) {
+		fSys := testEventsFS()
+		fSys["resources/events/past/2001/001_a.html"] = testEvent("[a]", "")
+		fSys["resources/events/past/2002/001_a.bmp"] = &fstest.MapFile{}
+		s := newTestSite(fSys)
+		s.Concurrency = 2
+		if err := s.addPastEvents(); err == nil || !strings.Contains(err.Error(), "2002") {
+			t.Errorf("wanted error for year with unsupported file, got %v", err)
+		}
+	})
+}
+
+var eventResourceLinkRE = regexp.MustCompile(`<a href="[^"]*">Video/Resources</a>`)
+
+func BenchmarkAddPastEvents(b *testing.B) {
+	fSys := testEventsFS()
+	for i := 1; i <= 50; i++ {
+		name := fmt.Sprintf("resources/events/past/%v/%03d_a.html", 2000+i%5, i)
+		fSys[name] = testEvent("[event]", "[resources]")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := newTestSite(fSys)
+		s.Concurrency = 1
+		if err := s.addPastEvents(); err != nil {
+			b.Fatalf("unwanted error: %v", err)
+		}
+	}
+}
+
+func TestParseEventTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantOk      bool
+		wantMissing []string
+	}{
+		{"valid", `{{define "event"}}a{{end}}{{define "resources"}}{{end}}`, true, nil},
+		{"no resources", `{{define "event"}}a{{end}}`, false, []string{"resources"}},
+		{"no event", `{{define "resources"}}b{{end}}`, false, []string{"event"}},
+		{"no templates", `<p>a</p>`, false, []string{"event", "resources"}},
+		{"bad syntax", `{{define "event"}}`, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			_, err := s.parseEventTemplate("a.html", []byte(test.data))
+			var ete *EventTemplateError
+			switch {
+			case test.wantOk:
+				if err != nil {
+					t.Errorf("unwanted error: %v", err)
+				}
+			case err == nil:
+				t.Errorf("wanted error")
+			case test.wantMissing == nil:
+				if errors.As(err, &ete) {
+					t.Errorf("wanted parse error, got %v", err)
+				}
+			case !errors.As(err, &ete):
+				t.Errorf("wanted event template error, got %v", err)
+			default:
+				if want, got := "a.html", ete.File; want != got {
+					t.Errorf("files not equal: wanted %q, got %q", want, got)
+				}
+				if want, got := test.wantMissing, ete.Missing; !reflect.DeepEqual(want, got) {
+					t.Errorf("missing templates not equal: wanted %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEventFiles(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/future/001_a.html"] = testEvent("[a]", "")
+	fSys["resources/events/future/002_b.html"] = &fstest.MapFile{Data: []byte(`{{define "event"}}b{{end}}`)}
+	fSys["resources/events/past/2023/001_c.html"] = &fstest.MapFile{Data: []byte(`{{define "resources"}}c{{end}}`)}
+	fSys["resources/events/past/2023/001_c.jpg"] = &fstest.MapFile{}
+	s := newTestSite(fSys)
+	err := s.addEvents()
+	if err == nil {
+		t.Fatalf("wanted error")
+	}
+	for _, want := range []string{"future/002_b.html", "2023/001_c.html"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("wanted error to contain %q, got %v", want, err)
+		}
+	}
+	if len(s.files) != 0 {
+		t.Errorf("wanted no files written before validation, got %v", s.files)
+	}
+}
+
+func TestAddEventInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		name      string
+		addEvents func(s *Site) error
+	}{
+		{"validated", (*Site).addEvents},
+		{"past", (*Site).addPastEvents},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := testEventsFS()
+			fSys["resources/events/future/001_b.html"] = testEvent("[b]", "")
+			fSys["resources/events/past/2023/001_a.html"] = &fstest.MapFile{Data: []byte("{{define \"event\"}}\xff{{.Broken")}
+			s := newTestSite(fSys)
+			err := test.addEvents(&s.Site)
+			switch {
+			case err == nil:
+				t.Errorf("wanted error")
+			case !strings.Contains(err.Error(), "001_a.html is not valid UTF-8"):
+				t.Errorf("wanted encoding error, got %v", err)
+			case strings.Contains(err.Error(), "parsing"):
+				t.Errorf("wanted encoding to be checked before the template is parsed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAddEventsMultiError(t *testing.T) {
+	t.Run("invalid templates", func(t *testing.T) {
+		fSys := testEventsFS()
+		fSys["resources/events/future/001_a.html"] = &fstest.MapFile{Data: []byte(`{{define "event"}}{{.Broken`)}
+		fSys["resources/events/past/2023/001_b.html"] = &fstest.MapFile{Data: []byte(`{{define "event"}}b{{end}}`)}
+		s := newTestSite(fSys)
+		err := s.addEvents()
+		var errs MultiError
+		if !errors.As(err, &errs) {
+			t.Fatalf("wanted MultiError, got %v", err)
+		}
+		if len(errs) != 2 {
+			t.Fatalf("wanted 2 errors, got %v: %v", len(errs), err)
+		}
+		var templateErr *EventTemplateError
+		if !errors.As(errs[1], &templateErr) || templateErr.File != "resources/events/past/2023/001_b.html" {
+			t.Errorf("wanted second error to be for missing template, got %v", errs[1])
+		}
+		if !strings.Contains(errs[0].Error(), "future/001_a.html") {
+			t.Errorf("wanted first error to be for parse error, got %v", errs[0])
+		}
+	})
+	t.Run("large images", func(t *testing.T) {
+		fSys := testEventsFS()
+		fSys["resources/events/future/001_a.html"] = testEvent("[a]", "")
+		fSys["resources/events/past/2022/001_b.html"] = testEvent("[b]", "")
+		fSys["resources/events/past/2022/001_b.jpg"] = &fstest.MapFile{Data: make([]byte, kB50+1)}
+		fSys["resources/events/past/2023/001_c.html"] = testEvent("[c]", "")
+		fSys["resources/events/past/2023/001_c.jpg"] = &fstest.MapFile{Data: make([]byte, kB50+1)}
+		fSys["resources/events/past/2023/002_d.html"] = testEvent("[d]", "")
+		fSys["resources/events/past/2023/002_d.jpg"] = &fstest.MapFile{Data: make([]byte, kB50+1)}
+		s := newTestSite(fSys)
+		err := s.addEvents()
+		var errs MultiError
+		if !errors.As(err, &errs) {
+			t.Fatalf("wanted MultiError, got %v", err)
+		}
+		for i, want := range []string{"001_b.jpg", "001_c.jpg", "002_d.jpg"} {
+			if i >= len(errs) || !strings.Contains(errs[i].Error(), want) {
+				t.Errorf("wanted error %v to contain %q, got %v", i, want, err)
+			}
+		}
+	})
+}
+
+func TestAddExternalResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     string
+		wantOk   bool
+		want     string
+	}{
+		{"valid", "meeting-recording.url", "https://www.youtube.com/watch?v=abc&t=1\n", true, `<a href="https://www.youtube.com/watch?v=abc&amp;t=1">meeting recording</a>` + "\n"},
+		{"http", "slides.url", "http://example.com/slides.pdf", true, `<a href="http://example.com/slides.pdf">slides</a>` + "\n"},
+		{"order number", "004_jane_doe-slides.url", "https://example.com", true, `<a href="https://example.com">jane doe slides</a>` + "\n"},
+		{"escaped label", "<b>-notes.url", "https://example.com", true, `<a href="https://example.com">&lt;b&gt; notes</a>` + "\n"},
+		{"javascript scheme", "bad.url", "javascript:alert(1)", false, ""},
+		{"relative", "relative.url", "/resources/a.pdf", false, ""},
+		{"malformed", "malformed.url", "https://[::1", false, ""},
+		{"empty", "empty.url", "", false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fSys := fstest.MapFS{
+				"events/2023/" + test.fileName: &fstest.MapFile{Data: []byte(test.data)},
+			}
+			s := newTestSite(fSys)
+			var eg EventGroup
+			err := s.addExternalResource(&eg, "events/2023", test.fileName)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != eg.Resources.String():
+				t.Errorf("not equal: \n wanted: %q \n got:    %q", test.want, eg.Resources.String())
+			}
+		})
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageName  string
+		title     string
+		wantTitle string
+	}{
+		{"fallback to name", "Contact Us", "", "<title>Contact Us | TestSite</title>"},
+		{"name is site name", "TestSite", "", "<title>TestSite</title>"},
+		{"title", "Contact Us", "Contact The Forum", "<title>Contact The Forum</title>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.fSys = _siteFS
+			if err := s.addTitledPage(test.pageName, test.title, about, "contact-us.html", nil); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if got := string(s.files["dest/contact-us.html"]); !strings.Contains(got, test.wantTitle) {
+				t.Errorf("wanted page to contain %q", test.wantTitle)
+			}
+		})
+	}
+}
+
+func TestPageMeta(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultOGImage string
+		meta           PageMeta
+		want           []string
+		notWant        []string
+	}{
+		{
+			name:           "defaults",
+			defaultOGImage: "/images/logo.png",
+			want: []string{
+				`<meta property="og:title" content="Contact Us | TestSite">`,
+				`<meta property="og:description" content="Test Description">`,
+				`<meta property="og:image" content="https://example.com/images/logo.png">`,
+				`<meta name="twitter:card" content="summary_large_image">`,
+			},
+		},
+		{
+			name: "no image",
+			want: []string{
+				`<meta property="og:description" content="Test Description">`,
+				`<meta name="twitter:card" content="summary">`,
+			},
+			notWant: []string{`og:image`},
+		},
+		{
+			name:           "overrides",
+			defaultOGImage: "/images/logo.png",
+			meta: PageMeta{
+				OGImage:       "https://cdn.example.com/a.jpg",
+				OGDescription: `Talks & "tea"`,
+				TwitterCard:   "summary",
+			},
+			want: []string{
+				`<meta property="og:description" content="Talks &amp; &#34;tea&#34;">`,
+				`<meta property="og:image" content="https://cdn.example.com/a.jpg">`,
+				`<meta name="twitter:card" content="summary">`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.fSys = _siteFS
+			s.BaseURL = "https://example.com"
+			s.DefaultOGImage = test.defaultOGImage
+			if err := s.addPageWithMeta("Contact Us", "", about, "contact-us.html", "contact-us.html", test.meta, nil); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			got := string(s.files["dest/contact-us.html"])
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("wanted page to contain %q", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("did not want page to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestPageRobots(t *testing.T) {
+	tests := []struct {
+		name             string
+		defaultRobotsTag string
+		want             string
+	}{
+		{"default", "noindex, nofollow", `<meta name="robots" content="noindex, nofollow">`},
+		{"indexed", "index, follow", `<meta name="robots" content="index, follow">`},
+		{"none", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.fSys = _siteFS
+			s.DefaultRobotsTag = test.defaultRobotsTag
+			if err := s.addPage("Contact Us", about, "contact-us.html", nil); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			got := string(s.files["dest/contact-us.html"])
+			if len(test.want) == 0 {
+				if strings.Contains(got, `name="robots"`) {
+					t.Errorf("did not want robots meta tag, got %q", got)
+				}
+				return
+			}
+			if !strings.Contains(got, test.want) {
+				t.Errorf("wanted page to contain %q", test.want)
+			}
+		})
+	}
+	t.Run("event resources page", func(t *testing.T) {
+		s := newTestSite(nil)
+		s.fSys = _siteFS
+		s.DefaultRobotsTag = "index, follow"
+		if err := s.addEventResourcesPage("dest/resources/events/2023", "dest/resources/events/2023/001_a.html", EventMeta{}, bytes.NewBufferString("[a]")); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		got := string(s.files["dest/resources/events/2023/001_a.html"])
+		for _, want := range []string{`<meta name="robots" content="noindex, follow">`, `<a href="/past-events.html">`} {
+			if !strings.Contains(got, want) {
+				t.Errorf("wanted page to contain %q, got %q", want, got)
+			}
+		}
+		if strings.Contains(got, "javascript:") {
+			t.Errorf("wanted no javascript urls, which the content security policy blocks, got %q", got)
+		}
+	})
+}
+
+func TestBasePath(t *testing.T) {
+	s := newTestSite(nil)
+	s.fSys = _siteFS
+	s.BasePath = "/enlighten/"
+	if err := s.addPage("Contact Us", about, "contact-us.html", nil); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/contact-us.html"])
+	for _, want := range []string{
+		`<a href="/enlighten/">`,
+		`href="/enlighten/board-members.html"`,
+		`href="/enlighten/past-events.html"`,
+		`href="/enlighten/humans.txt"`,
+		`src="/enlighten/images/enlighten-logo.png"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted page to contain %q", want)
+		}
+	}
+	if strings.Contains(got, `href="/board-members.html"`) {
+		t.Errorf("wanted links to be under the base path")
+	}
+	t.Run("absolute urls", func(t *testing.T) {
+		fSys := testEventsFS()
+		fSys["resources/events/past/2023/2023-01-20_jane_doe.html"] = testEvent("birds", `<img src="images/events/2023/jane.jpg">`)
+		s := newTestSite(fSys)
+		s.BaseURL = "https://example.com"
+		s.BasePath = "/enlighten/"
+		if err := s.addPastEvents(); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		tests := []struct {
+			file string
+			want string
+		}{
+			{"dest/resources/events/2023/2023-01-20_jane_doe.html", `src="/enlighten/images/events/2023/jane.jpg"`},
+			{"dest/feeds/speakers/jane-doe.rss", "<link>https://example.com/enlighten/resources/events/2023/2023-01-20_jane_doe.html</link>"},
+		}
+		for _, test := range tests {
+			if got := string(s.files[test.file]); !strings.Contains(got, test.want) {
+				t.Errorf("wanted %v to contain %q, got:\n%s", test.file, test.want, got)
+			}
+		}
+		if want, got := "https://example.com/enlighten/", s.canonicalURL("home.html"); want != got {
+			t.Errorf("canonical urls not equal: wanted %q, got %q", want, got)
+		}
+	})
+}
+
+func TestURLPath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		p        string
+		want     string
+	}{
+		{"/", "/", "/"},
+		{"/", "home.html", "/home.html"},
+		{"", "/images/a.png", "/images/a.png"},
+		{"/enlighten/", "/", "/enlighten/"},
+		{"/enlighten/", "events/feed.atom", "/enlighten/events/feed.atom"},
+		{"/enlighten/", "", "/enlighten"},
+	}
+	for _, test := range tests {
+		s := newTestSite(nil)
+		s.BasePath = test.basePath
+		if got := s.urlPath(test.p); test.want != got {
+			t.Errorf("url path of %q under %q: wanted %q, got %q", test.p, test.basePath, test.want, got)
+		}
+	}
+}
+
+func TestLimitWrites(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		max      int
+		size     int
+		wantErr  bool
+	}{
+		{"small", "dest/home.html", 10, 9, false},
+		{"at limit", "dest/home.html", 10, 10, false},
+		{"oversized", "dest/home.html", 10, 11, true},
+		{"no limit", "dest/home.html", 0, 11, false},
+		{"binary copy", "dest/resources/events/2020/a.PDF", 10, 11, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.MaxOutputFileBytes = test.max
+			s.limitWrites()
+			name := test.fileName
+			err := s.writeFile(name, make([]byte, test.size))
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Fatalf("wanted error")
+				}
+				if !strings.Contains(err.Error(), name) {
+					t.Errorf("wanted error to name the file, got %v", err)
+				}
+				if _, ok := s.files[name]; ok {
+					t.Errorf("wanted oversized file to not be written")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case len(s.files[name]) != test.size:
+				t.Errorf("wanted file to be written")
+			}
+		})
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		destName string
+		want     string
+	}{
+		{"home", "https://example.com", "home.html", "https://example.com/"},
+		{"home with trailing slash", "https://example.com/", "home.html", "https://example.com/"},
+		{"page", "https://example.com", "contact-us.html", "https://example.com/contact-us.html"},
+		{"translated home", "https://example.com", "es/home.html", "https://example.com/es/home.html"},
+		{"no base url", "", "contact-us.html", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.BaseURL = test.baseURL
+			if got := s.canonicalURL(test.destName); test.want != got {
+				t.Errorf("not equal: wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+	t.Run("template", func(t *testing.T) {
+		for baseURL, want := range map[string]string{
+			"https://example.com": `<link rel="canonical" href="https://example.com/contact-us.html">`,
+			"":                    "",
+		} {
+			s := newTestSite(nil)
+			s.fSys = _siteFS
+			s.BaseURL = baseURL
+			if err := s.addPage("Contact Us", about, "contact-us.html", nil); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			got := string(s.files["dest/contact-us.html"])
+			switch {
+			case len(want) == 0:
+				if strings.Contains(got, `rel="canonical"`) {
+					t.Errorf("did not want canonical link without base url")
+				}
+			case !strings.Contains(got, want):
+				t.Errorf("wanted page to contain %q", want)
+			}
+		}
+	})
+}
+
+func TestEventJSONLD(t *testing.T) {
+	s := newTestSite(nil)
+	meta := EventMeta{
+		Name:        "Local Birds",
+		Speaker:     "Jane Doe",
+		StartDate:   "2024-01-19T19:00:00-08:00",
+		Location:    "Givens Community Center",
+		Description: "Birds & <b>bees</b>",
+	}
+	data, err := s.eventJSONLD(meta)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if bytes.Contains(data, []byte("<")) {
+		t.Errorf("wanted html characters to be escaped so the json cannot close the script tag: %s", data)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parsing json-ld: %v", err)
+	}
+	want := map[string]interface{}{
+		"@context":    "https://schema.org",
+		"@type":       "Event",
+		"name":        "Local Birds",
+		"startDate":   "2024-01-19T19:00:00-08:00",
+		"description": "Birds & <b>bees</b>",
+		"location":    map[string]interface{}{"@type": "Place", "name": "Givens Community Center"},
+		"performer":   map[string]interface{}{"@type": "Person", "name": "Jane Doe"},
+		"organizer":   map[string]interface{}{"@type": "Organization", "name": "TestSite"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestEventEntryMeta(t *testing.T) {
+	tests := []struct {
+		name          string
+		entry         EventEntry
+		meta          EventMeta
+		wantStartDate string
+	}{
+		{"day", EventEntry{File: "2024-01-19_jane_doe.html", Date: time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)}, EventMeta{}, "2024-01-19"},
+		{"month", EventEntry{File: "001_jane_doe.html", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, EventMeta{}, "2024-01"},
+		{"meta", EventEntry{File: "001_jane_doe.html", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, EventMeta{StartDate: "2024-01-19"}, "2024-01-19"},
+		{"no date", EventEntry{File: "jane_doe.html"}, EventMeta{}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.entry.meta(test.meta).StartDate; test.wantStartDate != got {
+				t.Errorf("start dates not equal: wanted %q, got %q", test.wantStartDate, got)
+			}
+		})
+	}
+}
+
+func TestEventResourcesPageJSONLD(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2023/001_jane_doe.html"] = &fstest.MapFile{Data: []byte(`{{/* meta: {"location": "Library", "startDate": "2023-01-20"} */}}` +
+		`{{define "event"}}[jane]{{end}}{{define "resources"}}[video]{{end}}`)}
+	s := newTestSite(fSys)
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got := string(s.files["dest/resources/events/2023/001_jane_doe.html"])
+	want := `<script type="application/ld+json">{"@context":"https://schema.org","@type":"Event","name":"Jane Doe","startDate":"2023-01-20","location":{"@type":"Place","name":"Library"},"performer":{"@type":"Person","name":"Jane Doe"},"organizer":{"@type":"Organization","name":"TestSite"}}</script>`
+	if !strings.Contains(got, want) {
+		t.Errorf("wanted resources page to contain %q, got %q", want, got)
+	}
+}
+
+func TestParseEventStartDate(t *testing.T) {
+	for _, s := range []string{"2024-01-19", "2024-01-19T19:00:00-08:00"} {
+		if _, err := parseEventStartDate(s); err != nil {
+			t.Errorf("unwanted error parsing %q: %v", s, err)
+		}
+	}
+	for _, s := range []string{"January 19", "2024-01-19 19:00", "2024-13-01"} {
+		if _, err := parseEventStartDate(s); err == nil {
+			t.Errorf("wanted error parsing %q", s)
+		}
+	}
+}
+
+func TestParseEventDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		wantOk bool
+		want   time.Time
+	}{
+		{"2024-01-19_jane_doe.html", true, time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-19-jane-doe.html", true, time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"2024-01_jane_doe.html", true, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-speaker.html", true, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-13_jane_doe.html", false, time.Time{}},
+		{"013_jane_doe.html", false, time.Time{}},
+		{"001_x.html", false, time.Time{}},
+		{"012_jane_doe.jpg", false, time.Time{}},
+		{"jan-speaker.html", false, time.Time{}},
+		{"jane_doe.html", false, time.Time{}},
+		{"2024.html", false, time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseEventDate(test.name)
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case !test.want.Equal(got):
+				t.Errorf("wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSortEventFiles(t *testing.T) {
+	fSys := fstest.MapFS{
+		"2024/001_a.html":                &fstest.MapFile{},
+		"2024/001_a.jpg":                 &fstest.MapFile{},
+		"2024/2024-03-15_c.html":         &fstest.MapFile{},
+		"2024/2024-02_b.html":            &fstest.MapFile{},
+		"2024/012_d.html":                &fstest.MapFile{},
+		"2024/013_e.html":                &fstest.MapFile{},
+		"2024/speaker_without_date.html": &fstest.MapFile{},
+	}
+	tests := []struct {
+		name         string
+		lenientDates bool
+		wantOk       bool
+		want         []string
+	}{
+		{"strict", false, false, nil},
+		{"lenient", true, true, []string{"2024-03-15_c.html", "2024-02_b.html", "013_e.html", "012_d.html", "001_a.jpg", "001_a.html", "speaker_without_date.html"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := fs.ReadDir(fSys, "2024")
+			if err != nil {
+				t.Fatalf("reading fixture directory: %v", err)
+			}
+			s := newTestSite(nil)
+			s.LenientDates = test.lenientDates
+			err = s.sortEventFiles(files)
+			switch {
+			case !test.wantOk:
+				if err == nil || !strings.Contains(err.Error(), "speaker_without_date.html") {
+					t.Errorf("wanted error for file without date, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				var got []string
+				for _, f := range files {
+					got = append(got, f.Name())
+				}
+				if !reflect.DeepEqual(test.want, got) {
+					t.Errorf("not equal: \n wanted: %q \n got:    %q", test.want, got)
+				}
+				if want, got := "warning: event file name", s.logs.String(); !strings.Contains(got, want) {
+					t.Errorf("wanted warning %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAddBuildComment(t *testing.T) {
+	buildTime := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		buildComment bool
+		buildTime    time.Time
+		version      string
+		wantComment  bool
+	}{
+		{"comment", true, buildTime, "v1.2.3", true},
+		{"disabled", false, buildTime, "v1.2.3", false},
+		{"no build time", true, time.Time{}, "v1.2.3", false},
+		{"no version", true, buildTime, "", false},
+	}
+	commentRE := regexp.MustCompile(`<!-- build: time=(\S+) version=(\S+) -->$`)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(testMainFS())
+			s.BuildComment = test.buildComment
+			s.BuildTime = test.buildTime
+			s.Version = test.version
+			if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			m := commentRE.FindStringSubmatch(string(s.files["dest/home.html"]))
+			switch {
+			case !test.wantComment:
+				if m != nil {
+					t.Errorf("unwanted build comment: %q", m[0])
+				}
+			case m == nil:
+				t.Errorf("wanted build comment at end of page: %q", s.files["dest/home.html"])
+			default:
+				gotTime, err := time.Parse(time.RFC3339, m[1])
+				if err != nil {
+					t.Errorf("parsing build time: %v", err)
+				}
+				if !test.buildTime.Equal(gotTime) {
+					t.Errorf("build times not equal: wanted %v, got %v", test.buildTime, gotTime)
+				}
+				if want, got := test.version, m[2]; want != got {
+					t.Errorf("versions not equal: wanted %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteVersion(t *testing.T) {
+	if want, got := "v9", siteVersion("v9"); want != got {
+		t.Errorf("wanted version flag %q to be used, got %q", want, got)
+	}
+}
+
+// testSiteFS is a small site with all of the main pages and two past events.
+func testSiteFS() fstest.MapFS {
+	fSys := testEventsFS()
+	for _, name := range []string{
+		"maintenance.html",
+		"404.html",
+		"about/board-members.html",
+		"about/contact-us.html",
+		"about/donations.html",
+		"about/location.html",
+		"about/mission-statement.html",
+		"about/purpose-statement.html",
+		"about/volunteers.html",
+		"events/calendar.html",
+		"events/meeting-link.html",
+		"events/sign-up.html",
+	} {
+		fSys["resources/"+name] = &fstest.MapFile{Data: []byte(`{{define "content"}}` + name + `{{end}}`)}
+	}
+	fSys["resources/images/logo.png"] = &fstest.MapFile{Data: []byte("png")}
+	fSys["resources/about/images/jane.jpg"] = &fstest.MapFile{Data: []byte("jpg")}
+	fSys["resources/robots.txt"] = &fstest.MapFile{Data: []byte("User-agent: *")}
+	fSys["resources/events/future/001_a.html"] = testEvent("[a]", "")
+	fSys["resources/events/past/2023/001_b.html"] = testEvent("[b]", "[b video]")
+	fSys["resources/events/past/2023/002_c.html"] = testEvent("[c]", "[c video]")
+	return fSys
+}
+
+func TestCleanDest(t *testing.T) {
+	errRemove := errors.New("permission denied")
+	tests := []struct {
+		name      string
+		removeErr error
+		wantOk    bool
+	}{
+		{"removes old files", nil, true},
+		{"missing dest", os.ErrNotExist, true},
+		{"remove error", errRemove, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			s.files["dest/old.html"] = []byte("old")
+			s.files["other/keep.html"] = []byte("keep")
+			removeAll := s.removeAll
+			s.removeAll = func(path string) error {
+				if test.removeErr != nil {
+					return test.removeErr
+				}
+				return removeAll(path)
+			}
+			var madeDirs []string
+			s.mkdirAll = func(path string) error {
+				madeDirs = append(madeDirs, path)
+				return nil
+			}
+			err := s.cleanDest()
+			switch {
+			case !test.wantOk:
+				if !errors.Is(err, errRemove) {
+					t.Errorf("wanted remove error, got %v", err)
+				}
+				if len(madeDirs) != 0 {
+					t.Errorf("did not want directories to be made, got %v", madeDirs)
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				if _, ok := s.files["dest/old.html"]; ok && test.removeErr == nil {
+					t.Errorf("wanted old file to be removed")
+				}
+				if _, ok := s.files["other/keep.html"]; !ok {
+					t.Errorf("wanted file outside of dest to be kept")
+				}
+				if want := []string{"dest"}; !slices.Equal(want, madeDirs) {
+					t.Errorf("made directories not equal: wanted %v, got %v", want, madeDirs)
+				}
+			}
+		})
+	}
+}
+
+func TestAddMain(t *testing.T) {
+	s := newTestSite(testSiteFS())
+	if err := s.addMain(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	tests := []struct {
+		name         string
+		wantContains string
+	}{
+		{"dest/home.html", "<title>Home Page</title>"},
+		{"dest/maintenance.html", "maintenance.html"},
+		{"dest/404.html", "<title>Page Not Found</title>"},
+		{"dest/board-members.html", "about/board-members.html"},
+		{"dest/contact-us.html", "about/contact-us.html"},
+		{"dest/donations.html", "about/donations.html"},
+		{"dest/location.html", "about/location.html"},
+		{"dest/mission-statement.html", "about/mission-statement.html"},
+		{"dest/purpose-statement.html", "about/purpose-statement.html"},
+		{"dest/volunteers.html", "about/volunteers.html"},
+		{"dest/calendar.html", "events/calendar.html"},
+		{"dest/meeting-link.html", "events/meeting-link.html"},
+		{"dest/sign-up.html", "events/sign-up.html"},
+		{"dest/images/logo.png", "png"},
+		{"dest/images/board/jane.jpg", "jpg"},
+		{"dest/robots.txt", "User-agent: *"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := s.files[test.name]
+			if !ok {
+				t.Fatalf("file not written")
+			}
+			if !strings.Contains(string(got), test.wantContains) {
+				t.Errorf("wanted file to contain %q, got %q", test.wantContains, got)
+			}
+		})
+	}
+	if want, got := len(tests), len(s.files); want != got {
+		names := make([]string, 0, len(s.files))
+		for name := range s.files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		t.Errorf("wanted %v files, got %v: %v", want, got, names)
+	}
+}
+
+func TestAddPastEventsWritesPages(t *testing.T) {
+	tests := []struct {
+		name        string
+		oneResource bool
+		want        map[string]string
+		notWant     []string
+	}{
+		{
+			name: "resources pages",
+			want: map[string]string{
+				"dest/past-events.html":                 "[c]",
+				"dest/resources/events/2023/001_b.html": "[b video]",
+				"dest/resources/events/2023/002_c.html": "[c video]",
+			},
+			notWant: []string{"dest/videos-and-resources.html"},
+		},
+		{
+			name:        "one resource",
+			oneResource: true,
+			want: map[string]string{
+				"dest/past-events.html":          "[b]",
+				"dest/videos-and-resources.html": "2023:[c video][b video]",
+			},
+			notWant: []string{"dest/resources/events/2023/001_b.html", "dest/resources/events/2023/002_c.html"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(testSiteFS())
+			s.OneResource = test.oneResource
+			if err := s.addPastEvents(); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			for name, want := range test.want {
+				got, ok := s.files[name]
+				switch {
+				case !ok:
+					t.Errorf("wanted %v to be written", name)
+				case !strings.Contains(string(got), want):
+					t.Errorf("wanted %v to contain %q, got %q", name, want, got)
+				}
+			}
+			for _, name := range test.notWant {
+				if _, ok := s.files[name]; ok {
+					t.Errorf("did not want %v to be written", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxKeywords = 100
+
+var (
+	htmlTagRE = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+	stopWords = map[string]bool{
+		"a": true, "about": true, "after": true, "all": true, "also": true, "an": true, "and": true,
+		"are": true, "as": true, "at": true, "be": true, "been": true, "but": true, "by": true,
+		"can": true, "for": true, "from": true, "has": true, "have": true, "he": true, "her": true,
+		"his": true, "how": true, "i": true, "in": true, "into": true, "is": true, "it": true,
+		"its": true, "more": true, "not": true, "of": true, "on": true, "or": true, "our": true,
+		"she": true, "so": true, "that": true, "the": true, "their": true, "them": true, "they": true,
+		"this": true, "to": true, "was": true, "we": true, "were": true, "what": true, "when": true,
+		"which": true, "who": true, "will": true, "with": true, "you": true, "your": true,
+	}
+)
+
+// plainText removes the tags from the html and collapses whitespace.
+func plainText(htmlText string) string {
+	text := htmlTagRE.ReplaceAllString(htmlText, " ")
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// buildKeywordCloud counts the most frequent words in the events that are not stop words.
+func (*Site) buildKeywordCloud(yrs []EventGroup) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, yr := range yrs {
+		for _, e := range yr.Entries {
+			text := plainText(e.Content)
+			words := strings.FieldsFunc(text, func(r rune) bool {
+				return !unicode.IsLetter(r) && r != '\''
+			})
+			for _, w := range words {
+				w = strings.ToLower(strings.Trim(w, "'"))
+				if len(w) > 1 && !stopWords[w] {
+					counts[w]++
+				}
+			}
+		}
+	}
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	slices.SortFunc(words, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	if len(words) > maxKeywords {
+		for _, w := range words[maxKeywords:] {
+			delete(counts, w)
+		}
+	}
+	return counts, nil
+}
+
+func (s *Site) addKeywordCloud(yrs []EventGroup) error {
+	cloud, err := s.buildKeywordCloud(yrs)
+	if err != nil {
+		return fmt.Errorf("building keyword cloud: %w", err)
+	}
+	data, err := json.Marshal(cloud)
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	dest := path.Join(s.dest, "keyword-cloud.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing keyword cloud: %w", err)
+	}
+	return nil
+}
+
+// addPlainTextEvent writes the title and text of the event, without html, to a file next to its resources page.
+func (s *Site) addPlainTextEvent(eg *EventGroup, year, eventHtmlName string) error {
+	i := slices.IndexFunc(eg.Entries, func(e EventEntry) bool {
+		return e.File == eventHtmlName
+	})
+	if i < 0 {
+		return fmt.Errorf("no event entry for %v", eventHtmlName)
+	}
+	e := eg.Entries[i]
+	text := e.Title + "\n\n" + plainText(e.Content) + "\n"
+	destName := strings.TrimSuffix(eventHtmlName, path.Ext(eventHtmlName)) + ".txt"
+	destDir := path.Join(s.dest, resources, events, year)
+	dest := path.Join(destDir, destName)
+	if err := s.checkFilenameLen(dest); err != nil {
+		return err
+	}
+	if err := s.mkdirAll(destDir); err != nil {
+		return fmt.Errorf("making directory: %w", err)
+	}
+	if err := s.writeFile(dest, []byte(text)); err != nil {
+		return fmt.Errorf("writing plain text event: %w", err)
+	}
+	return nil
+}
+
+type (
+	// indexedPage is a rendered page to add to the search corpus.
+	indexedPage struct {
+		Path  string
+		Title string
+		HTML  string
+	}
+	searchDocument struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+)
+
+var mainContentRE = regexp.MustCompile(`(?s)<main[^>]*>(.*)</main>`)
+
+// addSearchCorpus writes the text of the main content of the pages for client-side search libraries such as Lunr.
+func (s *Site) addSearchCorpus(pages []indexedPage) error {
+	docs := make([]searchDocument, len(pages))
+	for i, p := range pages {
+		content := p.HTML
+		if m := mainContentRE.FindStringSubmatch(content); m != nil {
+			content = m[1]
+		}
+		docs[i] = searchDocument{
+			ID:    p.Path,
+			Title: p.Title,
+			Body:  plainText(content),
+		}
+	}
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	dest := path.Join(s.dest, "search-corpus.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing search corpus: %w", err)
+	}
+	return nil
+}
+
+const searchExcerptLen = 200
+
+type searchIndexEntry struct {
+	Path    string `json:"path"`
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+}
+
+var (
+	titleRE        = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	bodyRE         = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	invisibleTagRE = regexp.MustCompile(`(?is)<(script|style|template)[^>]*>.*?</(script|style|template)>`)
+)
+
+// recordHTMLWrites remembers the html files the site writes for the search index.
+func (s *Site) recordHTMLWrites() {
+	writeFile := s.writeFile
+	s.writeFile = func(name string, data []byte) error {
+		if err := writeFile(name, data); err != nil {
+			return err
+		}
+		if path.Ext(name) != ".html" {
+			return nil
+		}
+		p := "/" + strings.TrimPrefix(strings.TrimPrefix(name, s.dest), "/")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.htmlFiles == nil {
+			s.htmlFiles = make(map[string][]byte)
+		}
+		s.htmlFiles[p] = data
+		return nil
+	}
+}
+
+// addSearchIndex writes search-index.json, which has the title and start of the visible text of each html file that was recorded.
+// The text is taken from the main content of the file if it has any.
+func (s *Site) addSearchIndex() error {
+	entries := make([]searchIndexEntry, 0, len(s.htmlFiles))
+	for p, data := range s.htmlFiles {
+		e := searchIndexEntry{
+			Path: p,
+		}
+		content := string(data)
+		if m := titleRE.FindStringSubmatch(content); m != nil {
+			e.Title = plainText(m[1])
+		}
+		if m := mainContentRE.FindStringSubmatch(content); m != nil {
+			content = m[1]
+		} else if m := bodyRE.FindStringSubmatch(content); m != nil {
+			content = m[1]
+		}
+		content = invisibleTagRE.ReplaceAllString(content, " ")
+		e.Excerpt = plainText(content)
+		if r := []rune(e.Excerpt); len(r) > searchExcerptLen {
+			e.Excerpt = string(r[:searchExcerptLen])
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b searchIndexEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("creating json: %w", err)
+	}
+	dest := path.Join(s.dest, "search-index.json")
+	if err := s.writeFile(dest, data); err != nil {
+		return fmt.Errorf("writing search index: %w", err)
+	}
+	return nil
+}
+
+var (
+	htmlCommentRE = regexp.MustCompile(`(?s)<!--.*?-->`)
+	landmarkREs   = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"main", regexp.MustCompile(`(?i)<main[\s>]|\srole="main"`)},
+		{"nav", regexp.MustCompile(`(?i)<nav[\s>]|\srole="navigation"`)},
+	}
+)
+
+// checkLandmarks ensures the page has the main and navigation landmarks that assistive technologies use to skip around.
+func (*Site) checkLandmarks(b []byte, name string) error {
+	b = htmlCommentRE.ReplaceAll(b, nil)
+	for _, l := range landmarkREs {
+		if !l.re.Match(b) {
+			return fmt.Errorf("%v has no <%v> landmark", name, l.name)
+		}
+	}
+	return nil
+}
+
+// wordsPerMinute is the average reading speed of adults.
+const wordsPerMinute = 200
+
+// wordCount counts the words of the html, ignoring tags.
+func (*Site) wordCount(html []byte) int {
+	return len(strings.Fields(plainText(string(html))))
+}
+
+// readingTime estimates how long it takes to read the words.
+func (*Site) readingTime(words int) time.Duration {
+	return time.Duration(words) * time.Minute / wordsPerMinute
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestPlainText(t *testing.T) {
+	html := "<div class=\"event\">\n<p><strong>Jane</strong> &amp; <!-- hidden -->John</p>\n</div>"
+	if want, got := "Jane & John", plainText(html); want != got {
+		t.Errorf("not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
+
+func TestBuildKeywordCloud(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2022/001_a.html"] = testEvent("<p>The birds of the Sound.</p>", "video")
+	fSys["resources/events/past/2023/001_b.html"] = testEvent("<p>Birds, <b>birds</b> and trees.</p>", "")
+	s := newTestSite(fSys)
+	if err := s.addPastEvents(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var cloud map[string]int
+	if err := json.Unmarshal(s.files["dest/keyword-cloud.json"], &cloud); err != nil {
+		t.Fatalf("parsing keyword cloud: %v", err)
+	}
+	if want, got := 3, cloud["birds"]; want != got {
+		t.Errorf("wanted birds to be counted %v times, got %v", want, got)
+	}
+	for _, w := range []string{"the", "of", "and", "video", "resources"} {
+		if _, ok := cloud[w]; ok {
+			t.Errorf("did not want %q in keyword cloud: %v", w, cloud)
+		}
+	}
+}
+
+func TestAddPlainTextEvent(t *testing.T) {
+	fSys := testEventsFS()
+	fSys["resources/events/past/2023/004_bird_walk.html"] = testEvent("<h4>Bird Walk</h4>\n<p>Meet at the <b>park</b> &amp; bring binoculars.</p>", "")
+	s := newTestSite(fSys)
+	s.PlainTextEvents = true
+	eg := &EventGroup{Year: "2023"}
+	if err := s.addEvent(eg, "resources/events/past/2023", "004_bird_walk.html", eg.Year); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, ok := s.files["dest/resources/events/2023/004_bird_walk.txt"]
+	if !ok {
+		t.Fatalf("plain text event not written: %v", s.files)
+	}
+	want := "Bird Walk\n\nBird Walk Meet at the park & bring binoculars.\n"
+	if want != string(got) {
+		t.Errorf("not equal:\nwanted: %q\ngot:    %q", want, got)
+	}
+}
+
+func TestAddSearchCorpus(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/about/contact-us.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}<p>Email <b>us</b></p>{{end}}`)}
+	s := newTestSite(fSys)
+	s.SearchCorpus = true
+	if err := s.addPage("Home Page", "", "home.html", nil); err != nil {
+		t.Fatalf("adding home page: %v", err)
+	}
+	if err := s.addPage("Contact Us", "about", "contact-us.html", nil); err != nil {
+		t.Fatalf("adding contact page: %v", err)
+	}
+	if err := s.addSearchCorpus(s.indexedPages); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	data := s.files["dest/search-corpus.json"]
+	var got []searchDocument
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parsing search corpus: %v", err)
+	}
+	want := []searchDocument{
+		{"/home.html", "Home Page", "home"},
+		{"/contact-us.html", "Contact Us", "Email us"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	if bytes.ContainsAny(data, "\n\t") {
+		t.Errorf("wanted minified json, got %s", data)
+	}
+}
+
+func TestAddSearchIndex(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/main.html"] = &fstest.MapFile{Data: []byte(`<html><head><title>{{.Page.Name}} &amp; more</title><style>p{}</style></head><body><nav>Home</nav><main><script>var x;</script>{{template "content" .Page.Data}}</main></body></html>`)}
+	fSys["resources/about/contact-us.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}<p>Email <b>us</b></p>{{end}}`)}
+	fSys["resources/about/long.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}<p>` + strings.Repeat("ab ", 100) + `</p>{{end}}`)}
+	s := newTestSite(fSys)
+	s.recordHTMLWrites()
+	if err := s.addPage("Contact Us", "about", "contact-us.html", nil); err != nil {
+		t.Fatalf("adding contact page: %v", err)
+	}
+	if err := s.addPage("Long", "about", "long.html", nil); err != nil {
+		t.Fatalf("adding long page: %v", err)
+	}
+	if err := s.writeFile("dest/plain.html", []byte("<body>Plain <i>page</i></body>")); err != nil {
+		t.Fatalf("writing html file: %v", err)
+	}
+	if err := s.writeFile("dest/robots.txt", []byte("User-agent: *")); err != nil {
+		t.Fatalf("writing text file: %v", err)
+	}
+	if err := s.addSearchIndex(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	data := s.files["dest/search-index.json"]
+	var got []searchIndexEntry
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parsing search index: %v", err)
+	}
+	want := []searchIndexEntry{
+		{"/contact-us.html", "Contact Us & more", "Email us"},
+		{"/long.html", "Long & more", strings.Repeat("ab ", 67)[:200]},
+		{"/plain.html", "", "Plain page"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestCheckLandmarks(t *testing.T) {
+	tests := []struct {
+		name   string
+		html   string
+		wantOk bool
+	}{
+		{"both", `<nav aria-label="site"></nav><main>hi</main>`, true},
+		{"roles", `<div role="navigation"></div><div role="main">hi</div>`, true},
+		{"no main", `<nav></nav><div>hi</div>`, false},
+		{"no nav", `<main>hi</main>`, false},
+		{"commented main", `<nav></nav><!-- <main>hi</main> -->`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSite(nil)
+			err := s.checkLandmarks([]byte(test.html), "page.html")
+			switch {
+			case !test.wantOk:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	fSys := testMainFS()
+	fSys["resources/main.html"] = &fstest.MapFile{Data: []byte(`<main>{{template "content" .Page.Data}}</main>{{.Page.WordCount}} words, {{.Page.ReadingTime}}`)}
+	words := strings.Repeat("<p>word word word <b>word</b></p>\n", 100)
+	fSys["resources/long.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}` + words + `{{end}}`)}
+	s := newTestSite(fSys)
+	if err := s.addPage("Long", "", "long.html", nil); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	p := s.pages[0]
+	if want, got := 400, p.WordCount; want != got {
+		t.Errorf("wanted %v words, got %v", want, got)
+	}
+	if want, got := 2*time.Minute, p.ReadingTime; want != got {
+		t.Errorf("wanted reading time of %v, got %v", want, got)
+	}
+	if want, got := "400 words, 2m0s", string(s.files["dest/long.html"]); !strings.HasSuffix(got, want) {
+		t.Errorf("wanted page to end with %q, got %q", want, got)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words int
+		want  time.Duration
+	}{
+		{0, 0},
+		{100, 30 * time.Second},
+		{200, time.Minute},
+		{1000, 5 * time.Minute},
+	}
+	s := newTestSite(nil)
+	for _, test := range tests {
+		if want, got := test.want, s.readingTime(test.words); want != got {
+			t.Errorf("reading time of %v words: wanted %v, got %v", test.words, want, got)
+		}
+	}
+}
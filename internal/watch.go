@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// srcFS reads the resources from a directory on disk instead of the embedded resources, so changes are seen without recompiling.
+type srcFS struct {
+	dir fs.FS
+}
+
+func newSrcFS(dir string) srcFS {
+	return srcFS{os.DirFS(dir)}
+}
+
+// Open opens the file in the directory with the name relative to the resources folder, such as resources/home.html.
+func (f srcFS) Open(name string) (fs.File, error) {
+	switch {
+	case name == resources:
+		return f.dir.Open(".")
+	case strings.HasPrefix(name, resources+"/"):
+		return f.dir.Open(strings.TrimPrefix(name, resources+"/"))
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+type (
+	// watcher rebuilds the site when the files in the source directory change.
+	watcher struct {
+		dir      string
+		interval time.Duration
+		build    func() error
+		out      io.Writer
+	}
+	fileVersion struct {
+		modTime time.Time
+		size    int64
+	}
+)
+
+// watch checks the files every interval until the context is done.
+// Errors are reported to the output so the site can be fixed while it is watched.
+func (w watcher) watch(ctx context.Context) {
+	prev, err := w.snapshot()
+	if err != nil {
+		fmt.Fprintf(w.out, "checking source files: %v\n", err)
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			curr, err := w.snapshot()
+			if err != nil {
+				fmt.Fprintf(w.out, "checking source files: %v\n", err)
+				continue
+			}
+			if maps.Equal(prev, curr) {
+				continue
+			}
+			prev = curr
+			w.rebuild()
+		}
+	}
+}
+
+// snapshot records the modification time and size of each file in the directory.
+func (w watcher) snapshot() (map[string]fileVersion, error) {
+	files := make(map[string]fileVersion)
+	err := filepath.WalkDir(w.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[p] = fileVersion{info.ModTime(), info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (w watcher) rebuild() {
+	start := time.Now()
+	if err := w.build(); err != nil {
+		writeError(w.out, "rebuilding site", err)
+		return
+	}
+	fmt.Fprintf(w.out, "rebuilt site in %v\n", time.Since(start).Round(time.Millisecond))
+}
+
+// writeError writes the error, listing each error of a MultiError on its own line.
+func writeError(w io.Writer, prefix string, err error) {
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		fmt.Fprintf(w, "%v: %v\n", prefix, err)
+		return
+	}
+	fmt.Fprintf(w, "%v: %v errors:\n", prefix, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(w, "  - %v\n", err)
+	}
+}
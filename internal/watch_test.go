@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSrcFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "events"), 0700); err != nil {
+		t.Fatalf("making directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events", "a.html"), []byte("a"), 0600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	fSys := newSrcFS(dir)
+	got, err := fs.ReadFile(fSys, "resources/events/a.html")
+	if err != nil || string(got) != "a" {
+		t.Errorf("wanted to read file, got %q, error %v", got, err)
+	}
+	entries, err := fs.ReadDir(fSys, resources)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "events" {
+		t.Errorf("wanted to read resources directory, got %v, error %v", entries, err)
+	}
+	if _, err := fs.ReadFile(fSys, "events/a.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("wanted file outside of resources to not exist, got %v", err)
+	}
+}
+
+// syncBuffer is a buffer that the watcher can write to while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatcherPolling(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "home.html")
+	if err := os.WriteFile(name, []byte("v1"), 0600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	builds := make(chan struct{})
+	buildErrs := []error{errors.New("broken template"), nil}
+	var out syncBuffer
+	w := watcher{
+		dir:      dir,
+		interval: 10 * time.Millisecond,
+		build: func() error {
+			err := buildErrs[0]
+			buildErrs = buildErrs[1:]
+			builds <- struct{}{}
+			return err
+		},
+		out: &out,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.watch(ctx)
+		close(done)
+	}()
+	waitForBuild := func() {
+		t.Helper()
+		select {
+		case <-builds:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("wanted rebuild after file changed, got output %q", out.String())
+		}
+	}
+	time.Sleep(5 * w.interval)                         // the initial snapshot is taken
+	for i, data := range []string{"version 2", "v3"} { // different sizes in case the modification times are the same
+		if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatalf("writing file %v: %v", i, err)
+		}
+		waitForBuild()
+	}
+	cancel()
+	<-done
+	got := out.String()
+	for _, want := range []string{"rebuilding site: broken template\n", "rebuilt site in "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted output to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"single", errors.New("bad"), "generating site: bad\n"},
+		{"multi", MultiError{errors.New("a"), errors.New("b")}, "generating site: 2 errors:\n  - a\n  - b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			writeError(&sb, "generating site", test.err)
+			if got := sb.String(); test.want != got {
+				t.Errorf("not equal: \n wanted: %q \n got:    %q", test.want, got)
+			}
+		})
+	}
+}
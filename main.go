@@ -1,18 +1,47 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-//go:embed build/site
+// all: includes .well-known, the files of which are served, and the configuration files of other hosts, which are not
+//
+//go:embed all:build/site
 var _siteFS embed.FS
 
-//go:generate go run enlightenkitsap.org/internal -dest=build/site -one-resource=false
+var feedPaths = []string{
+	"/rss.xml",
+	"/events.ics",
+	"/search-index.json",
+	"/events/feed.atom",
+}
+
+// buildTime and commit are set when the server is built with -ldflags "-X main.buildTime=... -X main.commit=..."
+var buildTime, commit string
+
+var proxyPaths = map[string]string{
+	"/": "/home.html",
+}
+
+var contentTypes = map[string]string{
+	".atom": "application/atom+xml; charset=utf-8",
+	".webp": "image/webp",
+}
+
+//go:generate go run enlightenkitsap.org/internal -dest=build/site -one-resource=false -precompress -csp-file=csp.txt
 func main() {
 	// uncomment the line below to debug compilation of the site:
 	// internal.Config{Dest: "build/site", OneResource: true}.WriteSite()
@@ -21,25 +50,132 @@ func main() {
 	if err := cfg.parseArgsAndEnv(os.Stdout, os.Args...); err != nil {
 		log.Fatalf("parsing program options: %v", err)
 	}
-	h, err := newHandler(_siteFS)
+	h, err := newHandler(*cfg, _siteFS, slog.Default())
 	if err != nil {
 		log.Fatalf("creating site page handler: %v", err)
 	}
 	addr := ":" + cfg.port
-	log.Println("Serving site at http://127.0.0.1" + addr)
-	log.Println("Press Ctrl-C to stop")
-	http.ListenAndServe(addr, h)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: h,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	servers := []*http.Server{srv}
+	if cfg.tlsEnabled() {
+		r, err := newCertReloader(cfg.tlsCert, cfg.tlsKey)
+		if err != nil {
+			log.Fatalf("creating tls certificate reloader: %v", err)
+		}
+		go r.watch(ctx, certReloaderInterval)
+		srv.TLSConfig = &tls.Config{
+			GetCertificate: r.GetCertificate,
+		}
+		if len(cfg.httpRedirect) != 0 {
+			redirectSrv := newHTTPRedirectServer(cfg.httpRedirect, cfg.port)
+			servers = append(servers, redirectSrv)
+			go func() {
+				log.Println("Redirecting http requests at " + cfg.httpRedirect + " to https")
+				// the site is still served over https if the redirect cannot be, such as when the server cannot bind to a privileged port
+				if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("serving https redirect: %v", err)
+				}
+			}()
+		}
+	}
+	go func() {
+		var err error
+		if cfg.tlsEnabled() {
+			log.Println("Serving site at https://127.0.0.1" + addr)
+			log.Println("Press Ctrl-C to stop")
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Println("Serving site at http://127.0.0.1" + addr)
+			log.Println("Press Ctrl-C to stop")
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("serving site: %v", err)
+		}
+	}()
+	<-ctx.Done()
+	stop()
+	if err := shutdownAll(servers, cfg.shutdownTimeout); err != nil {
+		log.Fatalf("shutting down servers: %v", err)
+	}
 }
 
-func newHandler(siteFS fs.FS) (http.Handler, error) {
+// shutdownAll shuts down each server, even if others fail to shut down.
+func shutdownAll(servers []*http.Server, timeout time.Duration) error {
+	var errs []error
+	for _, srv := range servers {
+		if err := shutdown(srv, timeout); err != nil {
+			errs = append(errs, fmt.Errorf("shutting down server at %v: %w", srv.Addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdown waits for active requests to finish before closing the server, forcing closure after the timeout.
+func shutdown(srv *http.Server, timeout time.Duration) error {
+	log.Println("Shutting down server")
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	log.Printf("Server shut down in %v", time.Since(start))
+	return nil
+}
+
+func newHandler(cfg config, siteFS fs.FS, logger *slog.Logger) (http.Handler, error) {
 	subFS, err := fs.Sub(siteFS, "build/site")
 	if err != nil {
 		return nil, fmt.Errorf("getting siteFS: %w", err)
 	}
 	hfs := http.FS(subFS)
-	h := http.FileServer(hfs)
-	h = withProxy(h, "/", "/home.html")
-	h = withBasicCacheControl(h)
-	h = withContentEncoding(h)
+	var h http.Handler = withHiddenFiles(http.FileServer(hfs))
+	switch page, err := fs.ReadFile(subFS, "404.html"); {
+	case err == nil:
+		h = withCustom404(h, page)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("reading 404 page: %w", err)
+	}
+	// files are compressed before the proxy paths are rewritten so the precompressed copies of their files are found
+	h = withContentEncoding(h, subFS)
+	h = withProxyMap(h, cfg.basePath, proxyPaths)
+	preloadData, err := fs.ReadFile(subFS, "preload.json")
+	switch {
+	case err == nil:
+		var resources []preloadResource
+		if err := json.Unmarshal(preloadData, &resources); err != nil {
+			return nil, fmt.Errorf("parsing preload resources: %w", err)
+		}
+		h = withPreloadHints(h, resources)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("reading preload resources: %w", err)
+	}
+	h = withContentTypes(h, contentTypes)
+	h = withFeedCORS(h, addBasePath(feedPaths, cfg.basePath))
+	h = withCORS(h, cfg.corsOriginList())
+	h = withCanonicalHeader(h, cfg.baseURL, cfg.basePath)
+	h = withPathSanitizer(h)
+	h = withBasicCacheControl(h, cfg.basePath)
+	h = withETag(h)
+	h = withSecurityHeaders(h, cfg.csp)
+	if cfg.maintenanceMode {
+		page, err := fs.ReadFile(subFS, "maintenance.html")
+		if err != nil {
+			return nil, fmt.Errorf("reading maintenance page: %w", err)
+		}
+		h = withMaintenance(h, page)
+	}
+	h = withHTTPSRedirect(h, cfg.forceHTTPS, cfg.httpsPort())
+	h = withMaxBodySize(h, cfg.maxBodyBytes)
+	h = withRateLimit(h, cfg.rateLimitRPS, cfg.rateLimitBurst)
+	h = withRequestLog(h, logger)
+	h = withHealthCheck(h, buildInfo{BuildTime: buildTime, Commit: commit})
 	return h, nil
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownAll(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	started := make(chan struct{})
+	busy := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-blocked
+		}),
+	}
+	idle := &http.Server{
+		Handler: http.NotFoundHandler(),
+	}
+	serveErrs := make(chan error, 2)
+	for _, srv := range []*http.Server{busy, idle} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listening: %v", err)
+		}
+		srv.Addr = l.Addr().String()
+		go func(srv *http.Server) {
+			serveErrs <- srv.Serve(l)
+		}(srv)
+	}
+	go http.Get("http://" + busy.Addr)
+	<-started
+	err := shutdownAll([]*http.Server{busy, idle}, time.Millisecond)
+	if err == nil {
+		t.Fatalf("wanted error shutting down server with active request")
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-serveErrs; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("wanted each server to be closed, got %v", err)
+		}
+	}
+}
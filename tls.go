@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloaderInterval is how often the certificate files are checked for changes.
+const certReloaderInterval = time.Minute
+
+// certReloader serves the certificate from the files, reloading it when the files change so the server does not need to be restarted when the certificate is renewed.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := r.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetCertificate returns the most recently loaded certificate, for tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reloadIfChanged loads the certificate if either file was modified after it was last loaded.
+func (r *certReloader) reloadIfChanged() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && !modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("loading certificate: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return true, nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("checking certificate file: %w", err)
+		}
+		if t := info.ModTime(); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+// watch reloads the certificate when the files change until the context is done.
+// The old certificate is kept if the new one cannot be loaded, such as when only one of the files has been replaced.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reloadIfChanged()
+			switch {
+			case err != nil:
+				log.Printf("reloading tls certificate: %v", err)
+			case reloaded:
+				log.Println("Reloaded tls certificate")
+			}
+		}
+	}
+}
+
+// newHTTPRedirectServer redirects all requests on the address to https on the port.
+func newHTTPRedirectServer(addr, httpsPort string) *http.Server {
+	h := withHTTPSRedirect(http.NotFoundHandler(), true, httpsPort)
+	return &http.Server{
+		Addr:    addr,
+		Handler: h,
+	}
+}
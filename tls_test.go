@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testServerName = "enlightenkitsap.test"
+
+// writeTestCert writes a self-signed certificate and key with the serial number to the files.
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: testServerName},
+		DNSNames:     []string{testServerName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert1 := writeTestCert(t, certFile, keyFile, 1)
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("creating reloader: %v", err)
+	}
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(h))
+	srv.TLS = &tls.Config{
+		GetCertificate: r.GetCertificate,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(cert1)
+	getSerial := func(roots *x509.CertPool) (int64, error) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:    roots,
+					ServerName: testServerName, // sent so the server calls GetCertificate
+				},
+			},
+		}
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer res.Body.Close()
+		return res.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+	if got, err := getSerial(roots); err != nil || got != 1 {
+		t.Fatalf("wanted first certificate, got serial %v, error %v", got, err)
+	}
+	t.Run("unchanged", func(t *testing.T) {
+		reloaded, err := r.reloadIfChanged()
+		if err != nil || reloaded {
+			t.Errorf("wanted no reload, got %v, error %v", reloaded, err)
+		}
+	})
+	t.Run("changed", func(t *testing.T) {
+		cert2 := writeTestCert(t, certFile, keyFile, 2)
+		later := time.Now().Add(time.Minute)
+		for _, name := range []string{certFile, keyFile} {
+			if err := os.Chtimes(name, later, later); err != nil {
+				t.Fatalf("changing modification time: %v", err)
+			}
+		}
+		reloaded, err := r.reloadIfChanged()
+		if err != nil || !reloaded {
+			t.Fatalf("wanted reload, got %v, error %v", reloaded, err)
+		}
+		roots.AddCert(cert2)
+		if got, err := getSerial(roots); err != nil || got != 2 {
+			t.Errorf("wanted second certificate, got serial %v, error %v", got, err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		later := time.Now().Add(2 * time.Minute)
+		if err := os.WriteFile(keyFile, []byte("bad key"), 0600); err != nil {
+			t.Fatalf("writing key: %v", err)
+		}
+		if err := os.Chtimes(keyFile, later, later); err != nil {
+			t.Fatalf("changing modification time: %v", err)
+		}
+		if _, err := r.reloadIfChanged(); err == nil {
+			t.Errorf("wanted error loading invalid key")
+		}
+		if got, err := getSerial(roots); err != nil || got != 2 {
+			t.Errorf("wanted second certificate to be kept, got serial %v, error %v", got, err)
+		}
+	})
+}
+
+func TestNewCertReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Errorf("wanted error for missing files")
+	}
+}
+
+func TestHTTPRedirectServer(t *testing.T) {
+	srv := newHTTPRedirectServer(":80", "443")
+	r := httptest.NewRequest("GET", "http://example.com:80/events/past-events.html?a=b", nil)
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, r)
+	if want, got := 301, w.Code; want != got {
+		t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+	}
+	if want, got := "https://example.com/events/past-events.html?a=b", w.Header().Get("Location"); want != got {
+		t.Errorf("locations not equal: wanted %q, got %q", want, got)
+	}
+}
+
+func TestForceHTTPSWithTLS(t *testing.T) {
+	cfg := config{
+		forceHTTPS: true,
+		basePath:   "/",
+	}
+	h, err := newHandler(cfg, _siteFS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("creating handler: %v", err)
+	}
+	srv := httptest.NewTLSServer(h)
+	defer srv.Close()
+	client := srv.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return fmt.Errorf("unwanted redirect to %v", req.URL)
+	}
+	res, err := client.Get(srv.URL + "/home.html")
+	if err != nil {
+		t.Fatalf("requesting page: %v", err)
+	}
+	defer res.Body.Close()
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Errorf("status codes not equal: wanted %v, got %v", want, got)
+	}
+}